@@ -0,0 +1,475 @@
+package cli
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+func configCmd() *cobra.Command {
+	configCmd := &cobra.Command{
+		Use:   "config [command]",
+		Short: "Manage nanohubctl configuration and profiles",
+		Long:  "Manage the nanohubctl config file and the named profiles in it",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Help(); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	configCmd.AddCommand(
+		viewConfigCmd(),
+		setConfigCmd(),
+		unsetConfigCmd(),
+		validateConfigCmd(),
+		useProfileCmd(),
+		listProfilesCmd(),
+		currentProfileCmd(),
+	)
+
+	return configCmd
+}
+
+// useProfileCmd sets the default profile in the config file
+func useProfileCmd() *cobra.Command {
+	useProfileCmd := &cobra.Command{
+		Use:   "use-profile NAME",
+		Short: "Set the profile used by default",
+		Long:  "Set the profile used when neither --profile nor NANOHUB_PROFILE are set",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			cf, err := utils.ReadConfigFile()
+			if err != nil {
+				return err
+			}
+			instances, err := utils.ReadInstances()
+			if err != nil {
+				return err
+			}
+			if _, ok := utils.AllProfiles(cf, instances)[name]; !ok {
+				return utils.NewUsageError("profile %q does not exist", name)
+			}
+			cf.Profile = name
+			if err := utils.WriteConfigFile(cf); err != nil {
+				return err
+			}
+			fmt.Printf("Switched to profile %s\n", name)
+			return nil
+		},
+	}
+
+	return useProfileCmd
+}
+
+// listProfilesCmd lists the profiles in the config file
+func listProfilesCmd() *cobra.Command {
+	listProfilesCmd := &cobra.Command{
+		Use:   "list-profiles",
+		Short: "List all profiles",
+		Long:  "List all profiles, the active one is marked with *",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cf, err := utils.ReadConfigFile()
+			if err != nil {
+				return err
+			}
+			instances, err := utils.ReadInstances()
+			if err != nil {
+				return err
+			}
+			profiles := utils.AllProfiles(cf, instances)
+			active := utils.ActiveProfile()
+			for _, name := range utils.ProfileNames(profiles) {
+				marker := " "
+				if name == active {
+					marker = "*"
+				}
+				fmt.Printf("%s %-20s %v\n", marker, name, profiles[name]["url"])
+			}
+			return nil
+		},
+	}
+
+	return listProfilesCmd
+}
+
+// currentProfileCmd shows the active profile
+func currentProfileCmd() *cobra.Command {
+	currentProfileCmd := &cobra.Command{
+		Use:   "current",
+		Short: "Show the active profile",
+		Long:  "Show the active profile and the URL it points at",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := utils.ActiveProfile()
+			if name == "" {
+				return fmt.Errorf("no profile is active")
+			}
+			fmt.Printf("%s (%s)\n", name, viper.GetString("url"))
+			return nil
+		},
+	}
+
+	return currentProfileCmd
+}
+
+// viewConfigCmd shows the effective settings and where each comes from
+func viewConfigCmd() *cobra.Command {
+	viewCmd := &cobra.Command{
+		Use:   "view",
+		Short: "Show the effective settings",
+		Long:  "Show the value nanohubctl uses for each setting after merging flags, NANOHUB_* env vars, the active profile, the config file and defaults, and where it came from. Secrets are masked.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cf, err := utils.ReadConfigFile()
+			if err != nil {
+				return err
+			}
+			instances, err := utils.ReadInstances()
+			if err != nil {
+				return err
+			}
+			profiles := utils.AllProfiles(cf, instances)
+
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			fmt.Fprintln(w, "SETTING\tVALUE\tSOURCE")
+			for _, key := range utils.Settings {
+				value := viper.GetString(key)
+				if slices.Contains(utils.SecretSettings, key) {
+					value = maskSecret(value)
+				}
+				fmt.Fprintf(w, "%s\t%s\t%s\n", key, value, settingSource(cmd, key, cf, profiles))
+			}
+			return w.Flush()
+		},
+	}
+
+	return viewCmd
+}
+
+// settingSource describes where the effective value of key comes from
+func settingSource(cmd *cobra.Command, key string, cf *utils.ConfigFile, profiles map[string]utils.Profile) string {
+	if f := cmd.Flags().Lookup(key); f != nil && f.Changed {
+		return "flag --" + key
+	}
+	if env, ok := utils.SettingEnv(key); ok {
+		return "env " + env
+	}
+	active := utils.ActiveProfile()
+	if key == "profile" {
+		switch {
+		case cf.Profile != "":
+			return "config file"
+		case active != "":
+			return "only provisioned instance"
+		}
+		return "unset"
+	}
+	if _, ok := profiles[active][key]; ok {
+		return "profile " + active
+	}
+	if _, ok := cf.Settings[key]; ok {
+		return "config file"
+	}
+	if viper.GetString(key) == "" {
+		return "unset"
+	}
+	return "default"
+}
+
+func maskSecret(secret string) string {
+	if len(secret) <= 8 {
+		return strings.Repeat("*", len(secret))
+	}
+	return strings.Repeat("*", len(secret)-4) + secret[len(secret)-4:]
+}
+
+// setConfigCmd writes a setting to the config file
+func setConfigCmd() *cobra.Command {
+	setCmd := &cobra.Command{
+		Use:   "set KEY VALUE",
+		Short: "Write a setting to the config file",
+		Long: `Write a setting to the config file. KEY is either a setting, which applies to
+every profile, or profiles.NAME.SETTING to set it in a single profile. The
+profile is created if it does not exist. Template values are set with
+values.KEY, with dots for nested keys.
+
+  nanohubctl config set retries 5
+  nanohubctl config set profiles.staging.url https://staging.nanohub.example.com/
+  nanohubctl config set profiles.staging.values.env stg`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			profile, key, err := parseSettingKey(args[0])
+			if err != nil {
+				return err
+			}
+			value, err := parseSettingValue(key, args[1])
+			if err != nil {
+				return err
+			}
+			cf, err := utils.ReadConfigFile()
+			if err != nil {
+				return err
+			}
+			switch {
+			case key == "values":
+				return utils.NewUsageError("values holds template values, set them one at a time with values.KEY")
+			case key == "profile":
+				instances, err := utils.ReadInstances()
+				if err != nil {
+					return err
+				}
+				if _, ok := utils.AllProfiles(cf, instances)[args[1]]; !ok {
+					return utils.NewUsageError("profile %q does not exist", args[1])
+				}
+				cf.Profile = args[1]
+			case profile != "":
+				if cf.Profiles == nil {
+					cf.Profiles = map[string]utils.Profile{}
+				}
+				if cf.Profiles[profile] == nil {
+					cf.Profiles[profile] = utils.Profile{}
+				}
+				setSetting(cf.Profiles[profile], key, value)
+			default:
+				if cf.Settings == nil {
+					cf.Settings = map[string]any{}
+				}
+				setSetting(cf.Settings, key, value)
+			}
+			return utils.WriteConfigFile(cf)
+		},
+	}
+
+	return setCmd
+}
+
+// unsetConfigCmd removes a setting from the config file
+func unsetConfigCmd() *cobra.Command {
+	unsetCmd := &cobra.Command{
+		Use:   "unset KEY",
+		Short: "Remove a setting from the config file",
+		Long:  "Remove a setting from the config file. KEY takes the same form as for set, profiles.NAME removes a whole profile.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cf, err := utils.ReadConfigFile()
+			if err != nil {
+				return err
+			}
+			if name, ok := strings.CutPrefix(args[0], "profiles."); ok {
+				if _, exists := cf.Profiles[name]; exists {
+					delete(cf.Profiles, name)
+					// Don't leave the config file pointing at a profile that is gone
+					if cf.Profile == name {
+						instances, err := utils.ReadInstances()
+						if err != nil {
+							return err
+						}
+						if _, ok := utils.AllProfiles(cf, instances)[name]; !ok {
+							cf.Profile = ""
+						}
+					}
+					return utils.WriteConfigFile(cf)
+				}
+			}
+			profile, key, err := parseSettingKey(args[0])
+			if err != nil {
+				return err
+			}
+			switch {
+			case key == "profile":
+				cf.Profile = ""
+			case profile != "":
+				if _, ok := cf.Profiles[profile]; !ok {
+					return utils.NewUsageError("profile %q does not exist", profile)
+				}
+				unsetSetting(cf.Profiles[profile], key)
+			default:
+				unsetSetting(cf.Settings, key)
+			}
+			return utils.WriteConfigFile(cf)
+		},
+	}
+
+	return unsetCmd
+}
+
+// setSetting sets key in settings. Template values, values.KEY, are set in
+// the values map, creating the maps along the way.
+func setSetting(settings map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	m := settings
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := settingsMap(m[part])
+		if !ok {
+			next = map[string]any{}
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+}
+
+// unsetSetting removes key from settings, template values from the values
+// map. Maps left empty are removed too.
+func unsetSetting(settings map[string]any, key string) {
+	part, rest, nested := strings.Cut(key, ".")
+	if !nested {
+		delete(settings, key)
+		return
+	}
+	m, ok := settingsMap(settings[part])
+	if !ok {
+		return
+	}
+	unsetSetting(m, rest)
+	if len(m) == 0 {
+		delete(settings, part)
+	}
+}
+
+// settingsMap returns v as a map if it is one. Maps nested in a profile are
+// decoded as utils.Profile.
+func settingsMap(v any) (map[string]any, bool) {
+	switch v := v.(type) {
+	case utils.Profile:
+		return v, true
+	case map[string]any:
+		return v, true
+	}
+	return nil, false
+}
+
+// parseSettingKey splits a KEY of the form SETTING or profiles.NAME.SETTING.
+// Profile names may contain dots, settings never do, apart from template
+// values: values.KEY, with dots for nested keys.
+func parseSettingKey(key string) (profile, setting string, err error) {
+	if rest, ok := strings.CutPrefix(key, "profiles."); ok {
+		i := strings.LastIndex(rest, ".")
+		if j := strings.Index(rest, ".values."); j > 0 {
+			i = j
+		}
+		if i <= 0 {
+			return "", "", utils.NewUsageError("%s must be of the form profiles.NAME.SETTING", key)
+		}
+		profile, setting = rest[:i], rest[i+1:]
+		if setting == "profile" {
+			return "", "", utils.NewUsageError("profile can not be set within a profile")
+		}
+	} else {
+		setting = key
+	}
+	if path, ok := strings.CutPrefix(setting, "values."); ok {
+		if slices.Contains(strings.Split(path, "."), "") {
+			return "", "", utils.NewUsageError("%s must be of the form values.KEY", setting)
+		}
+		return profile, setting, nil
+	}
+	if setting == "values" {
+		return profile, setting, nil
+	}
+	if !slices.Contains(utils.Settings, setting) {
+		return "", "", utils.NewUsageError("unknown setting %q, valid settings are: %s", setting, strings.Join(utils.Settings, ", "))
+	}
+	return profile, setting, nil
+}
+
+// parseSettingValue converts value to the type the setting key holds
+func parseSettingValue(key, value string) (any, error) {
+	switch key {
+	case "retries", "concurrency":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, utils.NewUsageError("%s must be a number", key)
+		}
+		return n, nil
+	case "insecure_skip_verify", "debug":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, utils.NewUsageError("%s must be true or false", key)
+		}
+		return b, nil
+	case "timeout", "retry_max_wait":
+		if _, err := time.ParseDuration(value); err != nil {
+			return nil, utils.NewUsageError("%s must be a duration such as 30s or 5m", key)
+		}
+	}
+	return value, nil
+}
+
+// validateConfigCmd checks that the effective settings work
+func validateConfigCmd() *cobra.Command {
+	validateCmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Check the settings against the server",
+		Long:  "Check that the URL is valid and that the credentials authenticate against the DDM and NanoCMD APIs",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var errs []error
+			check := func(name string, err error, detail string) {
+				if err != nil {
+					fmt.Printf("%-12s FAILED: %s\n", name+":", err)
+					errs = append(errs, err)
+					return
+				}
+				fmt.Printf("%-12s ok (%s)\n", name+":", detail)
+			}
+
+			baseUrl := viper.GetString("url")
+			check("url", validateURL(baseUrl), baseUrl)
+			apiKey, err := utils.APIKey(cmd.Context())
+			if err == nil && apiKey == "" {
+				err = utils.NewUsageError("no API key configured")
+			}
+			check("credentials", err, "api_user "+viper.GetString("api_user"))
+			if len(errs) > 0 {
+				return errs[0]
+			}
+
+			client, err := utils.NewClient(cmd.Context())
+			if err != nil {
+				return err
+			}
+			_, err = client.ListDeclarations(cmd.Context())
+			check("ddm", err, nanohub.DDMPath)
+			check("nanocmd", client.Ping(cmd.Context(), nanohub.NanoCMDPath, "profiles"), nanohub.NanoCMDPath)
+			if len(errs) > 0 {
+				return errs[0]
+			}
+			return nil
+		},
+	}
+
+	return validateCmd
+}
+
+func validateURL(rawUrl string) error {
+	if rawUrl == "" {
+		return utils.NewUsageError("no URL configured")
+	}
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return &utils.UsageError{Err: err}
+	}
+	if u.Scheme != "https" && u.Scheme != "http" {
+		return utils.NewUsageError("URL must start with http:// or https://")
+	}
+	if u.Host == "" {
+		return utils.NewUsageError("URL has no host")
+	}
+	return nil
+}
@@ -1,19 +1,18 @@
 package ddm
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
-	"log"
-	"os"
-	"path"
 	"slices"
-
-	"net/http"
+	"strings"
 
 	"github.com/spf13/cobra"
 
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/output"
 	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 )
 
 func declarationCmd() *cobra.Command {
@@ -34,6 +33,9 @@ func declarationCmd() *cobra.Command {
 		getDeclarationCmd(),
 		deleteDeclarationCmd(),
 		getSetsDeclarationCmd(),
+		diffDeclarationCmd(),
+		editDeclarationCmd(),
+		depsDeclarationCmd(),
 	)
 
 	return declarationCmd
@@ -56,29 +58,20 @@ func getDeclarationCmd() *cobra.Command {
 func getDeclarationFn(cmd *cobra.Command, args []string) error {
 	identifier := args[0]
 
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return err
-	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "declarations", identifier)
-	var resp *http.Response
-	err = getReq(ddmUrl.String(), &resp)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	decl, err := client.GetDeclaration(cmd.Context(), identifier)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	// Could be an array of strings or a proper dictionary
-	var jsonResponse interface{}
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.Result{
+		Data:    decl,
+		Columns: []string{"identifier", "type", "server token"},
+		Rows:    [][]string{{decl.Identifier(), decl.Type(), decl.ServerToken()}},
+		Names:   []string{decl.Identifier()},
+	})
 }
 
 // getSetsDeclarationCmd Lists set membership for a given declaration
@@ -98,40 +91,23 @@ func getSetsDeclarationCmd() *cobra.Command {
 func getSetsDeclarationFn(cmd *cobra.Command, args []string) error {
 	identifier := args[0]
 
-	ddmGetDeclsUrl, err := utils.GetDDMUrl()
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	ddmGetDeclsUrl.Path = path.Join(ddmGetDeclsUrl.Path, "declarations")
-	allDecls, nil := getAllDeclarations(&ddmGetDeclsUrl)
-	if !slices.Contains(allDecls, identifier) {
-		return fmt.Errorf("%s is not a valid declaration", identifier)
-	}
-
-	fmt.Printf("Getting set membership for identifier %s\n", identifier)
-	ddmUrl, err := utils.GetDDMUrl()
+	allDecls, err := client.ListDeclarations(cmd.Context())
 	if err != nil {
 		return err
 	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "/declaration-sets", identifier)
-	var resp *http.Response
-	err = getReq(ddmUrl.String(), &resp)
-	if err != nil {
-		return err
+	if !slices.Contains(allDecls, identifier) {
+		return fmt.Errorf("%s is not a valid declaration", identifier)
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+
+	sets, err := client.DeclarationSets(cmd.Context(), identifier)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	// Could be an array of strings or a proper dictionary
-	var jsonResponse []string
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.List("set", sets))
 }
 
 // createDeclarationCmd creates a new declaration based on a JSON file on disk
@@ -139,89 +115,141 @@ func createDeclarationCmd() *cobra.Command {
 	createCmd := &cobra.Command{
 		Use:     "create /path/to/declaration.json",
 		Short:   "Create declaration",
-		Long:    "Create declaration",
+		Long:    "Create declaration from a JSON, YAML (.yaml, .yml) or property list (.plist) file",
 		Args:    cobra.ExactArgs(1),
 		PreRunE: utils.ApplyPreExecFn,
 		RunE:    createDeclarationFn,
 	}
 
+	createCmd.Flags().Bool("skip-validation", false, "Upload without checking the declaration against its schema first")
+	addValuesFlags(createCmd)
+
 	return createCmd
 }
 
 func createDeclarationFn(cmd *cobra.Command, args []string) error {
 	jsonPath := args[0]
-	return createDeclaration(jsonPath)
+	skipValidation, err := cmd.Flags().GetBool("skip-validation")
+	if err != nil {
+		return err
+	}
+	values, err := templateValues(cmd)
+	if err != nil {
+		return err
+	}
+	files, err := declaration.ReadFiles([]string{jsonPath}, declaration.ReadOptions{Values: values, SkipValidation: skipValidation})
+	if err != nil {
+		return err
+	}
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+	// Tell a new declaration from an updated one in the result
+	existing, err := client.ListDeclarations(cmd.Context())
+	if err != nil {
+		return err
+	}
+	return utils.Apply(cmd.Context(), cmd.OutOrStdout(), putDeclarationTask(client, files[0], existing))
 }
 
-func createDeclaration(declJSONPaths ...string) error {
-	for _, jsonPath := range declJSONPaths {
-		jsonBytes, err := os.ReadFile(jsonPath)
-		if err != nil {
-			return err
-		}
-		// fmt.Printf("Creating declaration using %s\n", jsonPath)
-		ddmUrl, err := utils.GetDDMUrl()
-		if err != nil {
-			return err
-		}
-		ddmUrl.Path = path.Join(ddmUrl.Path, "declarations")
-		var resp *http.Response
-		err = putJsonReq(ddmUrl.String(), jsonBytes, &resp)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		switch resp.StatusCode {
-		case http.StatusNotModified:
-			// fmt.Printf("%s has not changed, no changes synced\n\n", jsonPath)
-		case http.StatusNoContent:
-			fmt.Printf("Successfully synced %s\n\n", jsonPath)
-		default:
-			fmt.Println(resp.Status)
-			_, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Fatalln(err)
-				fmt.Println("Error syncing declaration:", jsonPath)
-			}
-			fmt.Println("Error syncing declaration", jsonPath)
-			continue
+// putDeclarationTask returns the task uploading file. existing lists the
+// identifiers on the server, to tell new declarations from updated ones.
+func putDeclarationTask(client *nanohub.Client, file declaration.File, existing []string) utils.Task {
+	jsonPath, jsonBytes := file.Path, file.JSON
+	task := utils.Task{
+		Resource: "declaration",
+		Action:   "put",
+		Name:     jsonPath,
+		Source:   jsonPath,
+		Message:  fmt.Sprintf("Successfully synced %s", jsonPath),
+		Outcome:  utils.OutcomeCreated,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			return client.PutDeclaration(ctx, jsonBytes)
+		},
+	}
+	// Leave malformed files to the server to reject
+	var decl nanohub.Declaration
+	if json.Unmarshal(jsonBytes, &decl) == nil && decl.Identifier() != "" {
+		task.Name = decl.Identifier()
+		task.Key = decl.Identifier()
+		if slices.Contains(existing, decl.Identifier()) {
+			task.Outcome = utils.OutcomeUpdated
 		}
 	}
-	return nil
+	return task
 }
 
 // deleteDeclarationCmd deletes a declaration from the server
 func deleteDeclarationCmd() *cobra.Command {
 	deleteCmd := &cobra.Command{
-		Use:     "delete com.example.declaration",
-		Short:   "Delete declaration",
-		Long:    "Delete declaration",
+		Use:   "delete com.example.declaration",
+		Short: "Delete declaration",
+		Long: `Delete declaration. A declaration that is still in a set is not deleted unless
+--cascade removes it from its sets first or --force deletes it anyway.`,
 		Args:    cobra.ExactArgs(1),
 		PreRunE: utils.ApplyPreExecFn,
 		RunE:    deleteDeclarationFn,
 	}
 
+	deleteCmd.Flags().BoolP("force", "f", false, "Delete the declaration even if it is still in sets")
+	deleteCmd.Flags().Bool("cascade", false, "Remove the declaration from every set it is in, then delete it")
+	deleteCmd.Flags().Bool("dry-run", false, "Print what would be done without changing anything")
+
 	return deleteCmd
 }
 
 func deleteDeclarationFn(cmd *cobra.Command, args []string) error {
 	identifier := args[0]
-	fmt.Printf("Getting declaration for identifier %s\n", identifier)
-	ddmUrl, err := utils.GetDDMUrl()
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	cascade, err := cmd.Flags().GetBool("cascade")
 	if err != nil {
 		return err
 	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "declarations", identifier)
-	var resp *http.Response
-	err = deleteReq(ddmUrl.String(), &resp)
+	dryRun, err := cmd.Flags().GetBool("dry-run")
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
-		log.Fatalln(err)
+		return err
+	}
+
+	sets, err := client.DeclarationSets(cmd.Context(), identifier)
+	if err != nil {
+		return err
+	}
+	var tasks []utils.Task
+	if len(sets) > 0 {
+		switch {
+		case cascade:
+			for _, set := range sets {
+				tasks = append(tasks, removeSetTask(client, set, identifier))
+			}
+		case force:
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: %s is still in sets: %s\n", identifier, strings.Join(sets, ", "))
+		default:
+			return utils.NewConflictError("%s is still in sets: %s; use --cascade to remove it from them first or --force to delete it anyway", identifier, strings.Join(sets, ", "))
+		}
+	}
+	tasks = append(tasks, utils.Task{
+		Resource:         "declaration",
+		Action:           "delete",
+		Name:             identifier,
+		Message:          fmt.Sprintf("%s has been deleted", identifier),
+		UnchangedMessage: fmt.Sprintf("%s does not exist", identifier),
+		Outcome:          utils.OutcomeDeleted,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			return client.DeleteDeclaration(ctx, identifier)
+		},
+	})
+
+	if dryRun {
+		return utils.Plan(cmd.OutOrStdout(), tasks...)
 	}
-	fmt.Println(string(body))
-	return nil
+	fmt.Fprintf(cmd.ErrOrStderr(), "Deleting declaration for identifier %s\n", identifier)
+	return utils.Apply(cmd.Context(), cmd.OutOrStdout(), tasks...)
 }
@@ -1,16 +1,10 @@
 package ddm
 
 import (
-	"encoding/json"
-	"fmt"
-	"io"
-	"path"
-
-	"net/http"
-
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/macadmins/nanohubctl/internal/output"
 	"github.com/macadmins/nanohubctl/internal/utils"
 )
 
@@ -28,27 +22,13 @@ func declarationItemsCmd() *cobra.Command {
 
 func declarationItemsDdmFn(cmd *cobra.Command, args []string) error {
 	deviceID := viper.GetString("client_id")
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return err
-	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "declaration-items")
-
-	var resp *http.Response
-	err = getReqWithEnrollmentID(ddmUrl.String(), deviceID, &resp)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	resp, err := client.DeclarationItems(cmd.Context(), deviceID)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	var jsonResponse interface{}
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.Result{Data: resp})
 }
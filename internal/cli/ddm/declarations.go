@@ -1,58 +1,160 @@
 package ddm
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
 	"path"
-
-	"net/http"
-	"net/url"
+	"slices"
+	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/macadmins/nanohubctl/internal/output"
 	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 )
 
+// declarationListing is a row of the long listing of declarations
+type declarationListing struct {
+	Identifier  string   `json:"identifier"`
+	Type        string   `json:"type"`
+	ServerToken string   `json:"server_token"`
+	Sets        []string `json:"sets"`
+}
+
 func declarationsCmd() *cobra.Command {
 	declarationsCmd := &cobra.Command{
-		Use:     "declarations",
-		Short:   "List all declarations on the server",
-		Long:    "List all declarations currently on the server",
+		Use:   "declarations",
+		Short: "List all declarations on the server",
+		Long: `List all declarations currently on the server. With --long each declaration is
+fetched, --concurrency at a time, to show its Type, ServerToken and sets.`,
 		PreRunE: utils.ApplyPreExecFn,
-		RunE: func(cmd *cobra.Command, args []string) error {
-			ddmUrl, err := utils.GetDDMUrl()
-			if err != nil {
-				return err
-			}
-			ddmUrl.Path = path.Join(ddmUrl.Path, "declarations")
-			allDecls, nil := getAllDeclarations(&ddmUrl)
-			for _, decl := range allDecls {
-				fmt.Println(decl)
-			}
-			return nil
-		},
+		RunE:    declarationsFn,
 	}
 
+	declarationsCmd.Flags().BoolP("long", "l", false, "Show the Type, ServerToken and sets of each declaration")
+	declarationsCmd.Flags().String("type", "", "Only list declarations whose Type matches this glob, e.g. com.apple.configuration.passcode.*")
+	declarationsCmd.Flags().String("identifier-glob", "", "Only list declarations whose identifier matches this glob, e.g. 'com.example.*'")
+
 	return declarationsCmd
 }
 
-func getAllDeclarations(ddmUrl *url.URL) ([]string, error) {
-	var resp *http.Response
-	err := getReq(ddmUrl.String(), &resp)
+func declarationsFn(cmd *cobra.Command, args []string) error {
+	long, err := cmd.Flags().GetBool("long")
 	if err != nil {
-		return nil, err
+		return err
+	}
+	typeGlob, err := cmd.Flags().GetString("type")
+	if err != nil {
+		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	idGlob, err := cmd.Flags().GetString("identifier-glob")
+	if err != nil {
+		return err
+	}
+	for _, glob := range []string{typeGlob, idGlob} {
+		if _, err := path.Match(glob, ""); err != nil {
+			return utils.NewUsageError("invalid glob %q: %v", glob, err)
+		}
+	}
+
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+	allDecls, err := client.ListDeclarations(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if idGlob != "" {
+		allDecls = slices.DeleteFunc(allDecls, func(id string) bool {
+			matched, _ := path.Match(idGlob, id)
+			return !matched
+		})
+	}
+	if !long && typeGlob == "" {
+		result := output.List("identifier", allDecls)
+		result.Default = output.Name
+		return output.Print(cmd.OutOrStdout(), result)
+	}
+
+	listings, err := listDeclarations(cmd.Context(), client, allDecls, long)
+	if err != nil {
+		return err
+	}
+	if typeGlob != "" {
+		listings = slices.DeleteFunc(listings, func(listing declarationListing) bool {
+			matched, _ := path.Match(typeGlob, listing.Type)
+			return !matched
+		})
+	}
+	if !long {
+		identifiers := make([]string, len(listings))
+		for i, listing := range listings {
+			identifiers[i] = listing.Identifier
+		}
+		result := output.List("identifier", identifiers)
+		result.Default = output.Name
+		return output.Print(cmd.OutOrStdout(), result)
+	}
+
+	rows := make([][]string, len(listings))
+	names := make([]string, len(listings))
+	for i, listing := range listings {
+		rows[i] = []string{listing.Identifier, listing.Type, listing.ServerToken, strings.Join(listing.Sets, ",")}
+		names[i] = listing.Identifier
+	}
+	return output.Print(cmd.OutOrStdout(), output.Result{
+		Data:    listings,
+		Columns: []string{"identifier", "type", "server token", "sets"},
+		Rows:    rows,
+		Names:   names,
+		Default: output.Table,
+	})
+}
+
+// listDeclarations fetches the declarations identifiers concurrently, and
+// with withSets the sets each is in
+func listDeclarations(ctx context.Context, client *nanohub.Client, identifiers []string, withSets bool) ([]declarationListing, error) {
+	listings := make([]declarationListing, len(identifiers))
+	err := utils.ForEach(ctx, viper.GetInt("concurrency"), len(identifiers), func(ctx context.Context, i int) error {
+		decl, err := client.GetDeclaration(ctx, identifiers[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", identifiers[i], err)
+		}
+		listings[i] = declarationListing{
+			Identifier:  identifiers[i],
+			Type:        decl.Type(),
+			ServerToken: decl.ServerToken(),
+			Sets:        []string{},
+		}
+		return nil
+	})
+	if err != nil || !withSets {
+		return listings, err
+	}
+
+	// A request per set rather than per declaration
+	setNames, err := client.ListSets(ctx)
 	if err != nil {
 		return nil, err
 	}
-	resp.Body.Close()
-	// Could be an array of strings or a proper dictionary
-	var jsonResponse []string
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
+	members := make([][]string, len(setNames))
+	err = utils.ForEach(ctx, viper.GetInt("concurrency"), len(setNames), func(ctx context.Context, i int) error {
+		var err error
+		members[i], err = client.SetDeclarations(ctx, setNames[i])
+		return err
+	})
+	if err != nil {
 		return nil, err
 	}
-	return jsonResponse, nil
+	for i := range listings {
+		for j, set := range setNames {
+			if slices.Contains(members[j], listings[i].Identifier) {
+				listings[i].Sets = append(listings[i].Sets, set)
+			}
+		}
+	}
+	return listings, nil
 }
@@ -0,0 +1,325 @@
+package ddm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/output"
+	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// depsRecord is one declaration of the graph for structured output
+type depsRecord struct {
+	Identifier string                  `json:"identifier"`
+	Type       string                  `json:"type"`
+	Sets       []string                `json:"sets"`
+	References []declaration.Reference `json:"references"`
+	Problems   []declaration.Problem   `json:"problems"`
+}
+
+// depsDeclarationCmd shows the references between declarations
+func depsDeclarationCmd() *cobra.Command {
+	depsCmd := &cobra.Command{
+		Use:   "deps [com.example.declaration]",
+		Short: "Show the declarations a declaration refers to and check the references",
+		Long: `Show the declarations a declaration refers to, directly or through other
+declarations, or those of every declaration if none is given. References are
+found from the go-adm schema of each declaration type: asset references and the
+StandardConfigurations of activations.
+
+References to declarations that do not exist, that have the wrong class or that
+are not in every set the referring declaration is in are reported, and the
+command exits 1. Devices only receive the declarations of their sets, so such
+references do not resolve on the device.
+
+The server is checked unless --dir points at a sync directory.`,
+		Args:    cobra.MaximumNArgs(1),
+		PreRunE: utils.ApplyPreExecFn,
+		RunE:    depsDeclarationFn,
+	}
+
+	depsCmd.Flags().String("dir", "", "Check the declaration and set files in this directory instead of the server")
+	depsCmd.Flags().Bool("dot", false, "Print the graph in Graphviz DOT format")
+	addValuesFlags(depsCmd)
+
+	return depsCmd
+}
+
+func depsDeclarationFn(cmd *cobra.Command, args []string) error {
+	dir, err := cmd.Flags().GetString("dir")
+	if err != nil {
+		return err
+	}
+	dot, err := cmd.Flags().GetBool("dot")
+	if err != nil {
+		return err
+	}
+	if dot && output.Requested() {
+		return utils.NewUsageError("--dot cannot be combined with --output, --jq or --template")
+	}
+
+	var graph *declaration.Graph
+	if dir != "" {
+		graph, err = localGraph(cmd, dir)
+	} else {
+		graph, err = serverGraph(cmd.Context())
+	}
+	if err != nil {
+		return err
+	}
+
+	ids := graph.Identifiers()
+	if len(args) > 0 {
+		if _, ok := graph.Declarations[args[0]]; !ok {
+			return fmt.Errorf("declaration %s not found", args[0])
+		}
+		ids = graph.Reachable(args[0])
+	}
+	problems := graph.Problems(ids)
+
+	switch {
+	case dot:
+		printDot(cmd.OutOrStdout(), graph, ids, problems)
+	case output.Requested():
+		if err := printDepsRecords(cmd.OutOrStdout(), graph, ids, problems); err != nil {
+			return err
+		}
+	default:
+		roots := args
+		if len(roots) == 0 {
+			roots = depsRoots(graph)
+		}
+		for _, id := range roots {
+			printTree(cmd.OutOrStdout(), graph, id)
+		}
+	}
+
+	if len(problems) > 0 {
+		for _, problem := range problems {
+			fmt.Fprintln(cmd.ErrOrStderr(), problem)
+		}
+		if len(problems) == 1 {
+			return fmt.Errorf("found 1 broken reference")
+		}
+		return fmt.Errorf("found %d broken references", len(problems))
+	}
+	return nil
+}
+
+// serverGraph builds the reference graph of the declarations and sets on the
+// server
+func serverGraph(ctx context.Context) (*declaration.Graph, error) {
+	client, err := utils.NewClient(ctx)
+	if err != nil {
+		return nil, err
+	}
+	identifiers, err := client.ListDeclarations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	decls := make([]map[string]any, len(identifiers))
+	err = utils.ForEach(ctx, viper.GetInt("concurrency"), len(identifiers), func(ctx context.Context, i int) error {
+		decl, err := client.GetDeclaration(ctx, identifiers[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", identifiers[i], err)
+		}
+		decls[i], err = normalizeServer(decl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]map[string]any, len(identifiers))
+	for i, id := range identifiers {
+		byID[id] = decls[i]
+	}
+
+	setNames, err := client.ListSets(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sets := make(map[string][]string, len(setNames))
+	for _, set := range setNames {
+		if sets[set], err = client.SetDeclarations(ctx, set); err != nil {
+			return nil, err
+		}
+	}
+	return declaration.NewGraph(byID, sets), nil
+}
+
+func normalizeServer(decl nanohub.Declaration) (map[string]any, error) {
+	declJSON, err := json.Marshal(decl)
+	if err != nil {
+		return nil, err
+	}
+	return declaration.Normalize(declJSON)
+}
+
+// localGraph builds the reference graph of the declaration and set files in a
+// sync directory
+func localGraph(cmd *cobra.Command, dir string) (*declaration.Graph, error) {
+	declPaths, setPaths, err := walkDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	values, err := templateValues(cmd)
+	if err != nil {
+		return nil, err
+	}
+	files, err := declaration.ReadFiles(declPaths, declaration.ReadOptions{Values: values, SkipValidation: true})
+	if err != nil {
+		return nil, err
+	}
+	decls := make(map[string]map[string]any, len(files))
+	for _, file := range files {
+		decl, err := declaration.Normalize(file.JSON)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid JSON: %w", file.Path, err)
+		}
+		id, _ := decl["Identifier"].(string)
+		if id == "" {
+			return nil, fmt.Errorf("%s: declaration has no Identifier", file.Path)
+		}
+		decls[id] = decl
+	}
+	sets, err := readSetFiles(setPaths, values)
+	if err != nil {
+		return nil, err
+	}
+	return declaration.NewGraph(decls, sets), nil
+}
+
+// depsRoots returns the declarations no other declaration refers to, usually
+// the activations
+func depsRoots(graph *declaration.Graph) []string {
+	referenced := map[string]bool{}
+	for _, refs := range graph.References {
+		for _, ref := range refs {
+			referenced[ref.To] = true
+		}
+	}
+	var roots []string
+	for _, id := range graph.Identifiers() {
+		if !referenced[id] {
+			roots = append(roots, id)
+		}
+	}
+	return roots
+}
+
+// depsLabel describes a node of the graph as identifier (type)
+func depsLabel(graph *declaration.Graph, id string) string {
+	if _, ok := graph.Declarations[id]; !ok {
+		return id + " (missing)"
+	}
+	return fmt.Sprintf("%s (%s)", id, graph.Type(id))
+}
+
+// printTree prints id and the declarations it refers to as an indented tree
+func printTree(w io.Writer, graph *declaration.Graph, id string) {
+	fmt.Fprintln(w, depsLabel(graph, id))
+	var walk func(id string, depth int, path []string)
+	walk = func(id string, depth int, path []string) {
+		for _, ref := range graph.References[id] {
+			indent := strings.Repeat("  ", depth)
+			field := strings.TrimPrefix(ref.Field, "Payload.")
+			if slices.Contains(path, ref.To) {
+				fmt.Fprintf(w, "%s%s -> %s (cycle)\n", indent, field, ref.To)
+				continue
+			}
+			fmt.Fprintf(w, "%s%s -> %s\n", indent, field, depsLabel(graph, ref.To))
+			walk(ref.To, depth+1, append(path, ref.To))
+		}
+	}
+	walk(id, 1, []string{id})
+}
+
+// printDot prints the declarations in ids and their references as a Graphviz
+// digraph. Missing declarations and broken references are drawn in red.
+func printDot(w io.Writer, graph *declaration.Graph, ids []string, problems []declaration.Problem) {
+	broken := map[declaration.Reference]bool{}
+	for _, problem := range problems {
+		broken[problem.Reference] = true
+	}
+	fmt.Fprintln(w, "digraph declarations {")
+	fmt.Fprintln(w, "\trankdir=LR;")
+	fmt.Fprintln(w, "\tnode [shape=box];")
+	nodes := map[string]bool{}
+	node := func(id string) {
+		if nodes[id] {
+			return
+		}
+		nodes[id] = true
+		if _, ok := graph.Declarations[id]; !ok {
+			fmt.Fprintf(w, "\t%s [label=%s, style=dashed, color=red];\n", strconv.Quote(id), strconv.Quote(id+"\n(missing)"))
+			return
+		}
+		fmt.Fprintf(w, "\t%s [label=%s];\n", strconv.Quote(id), strconv.Quote(id+"\n"+graph.Type(id)))
+	}
+	for _, id := range ids {
+		node(id)
+		for _, ref := range graph.References[id] {
+			node(ref.To)
+		}
+	}
+	for _, id := range ids {
+		for _, ref := range graph.References[id] {
+			attrs := "label=" + strconv.Quote(strings.TrimPrefix(ref.Field, "Payload."))
+			if broken[ref] {
+				attrs += ", color=red"
+			}
+			fmt.Fprintf(w, "\t%s -> %s [%s];\n", strconv.Quote(ref.From), strconv.Quote(ref.To), attrs)
+		}
+	}
+	fmt.Fprintln(w, "}")
+}
+
+// printDepsRecords prints one record per declaration in ids that exists
+func printDepsRecords(w io.Writer, graph *declaration.Graph, ids []string, problems []declaration.Problem) error {
+	records := []depsRecord{}
+	var rows [][]string
+	for _, id := range ids {
+		if _, ok := graph.Declarations[id]; !ok {
+			continue
+		}
+		record := depsRecord{
+			Identifier: id,
+			Type:       graph.Type(id),
+			Sets:       graph.SetsOf(id),
+			References: graph.References[id],
+			Problems:   []declaration.Problem{},
+		}
+		if record.Sets == nil {
+			record.Sets = []string{}
+		}
+		if record.References == nil {
+			record.References = []declaration.Reference{}
+		}
+		var to []string
+		for _, ref := range record.References {
+			to = append(to, ref.To)
+		}
+		for _, problem := range problems {
+			if problem.From == id {
+				record.Problems = append(record.Problems, problem)
+			}
+		}
+		records = append(records, record)
+		rows = append(rows, []string{id, record.Type, strings.Join(record.Sets, ","), strings.Join(to, ","), strconv.Itoa(len(record.Problems))})
+	}
+	return output.Print(w, output.Result{
+		Data:    records,
+		Columns: []string{"identifier", "type", "sets", "references", "problems"},
+		Rows:    rows,
+	})
+}
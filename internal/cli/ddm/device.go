@@ -1,19 +1,16 @@
 package ddm
 
 import (
-	"encoding/json"
+	"context"
 	"fmt"
-	"io"
-	"log"
-	"path"
 	"strings"
 
-	"net/http"
-
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 
+	"github.com/macadmins/nanohubctl/internal/output"
 	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 )
 
 // deviceCmd manages set membership for a given device
@@ -58,28 +55,15 @@ func getDeviceCmd() *cobra.Command {
 
 func getdeviceFn(cmd *cobra.Command, args []string) error {
 	deviceID := viper.GetString("client_id")
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return err
-	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "enrollment-sets", deviceID)
-	var resp *http.Response
-	err = getReq(ddmUrl.String(), &resp)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	sets, err := client.EnrollmentSets(cmd.Context(), deviceID)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	var jsonResponse interface{}
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.List("set", sets))
 }
 
 // addDeviceCmd applies a given set to the provided device ID
@@ -101,27 +85,21 @@ func addDeviceFn(cmd *cobra.Command, args []string) error {
 
 	set := args[0]
 
-	resp, err := addOrDeletedeviceItem("add", deviceID, set)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-
-	defer resp.Body.Close()
-	switch resp.StatusCode {
-	case http.StatusNotModified:
-		fmt.Printf("%s is already in %s\n", deviceID, set)
-	case http.StatusNoContent:
-		fmt.Printf("%s has been added to %s\n", deviceID, set)
-	default:
-		fmt.Println(resp.Status)
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalln(err)
-		}
-		return fmt.Errorf(string(body))
-	}
-
-	return nil
+	return utils.Apply(cmd.Context(), cmd.OutOrStdout(), utils.Task{
+		Resource:         "enrollment-set",
+		Action:           "add",
+		Name:             fmt.Sprintf("%s in set %s", deviceID, set),
+		Message:          fmt.Sprintf("%s has been added to %s", deviceID, set),
+		UnchangedMessage: fmt.Sprintf("%s is already in %s", deviceID, set),
+		Outcome:          utils.OutcomeCreated,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			return client.AddEnrollmentSet(ctx, deviceID, set)
+		},
+	})
 }
 
 // removeDeviceCmd removes a specified device ID from a given set
@@ -143,56 +121,23 @@ func removeDeviceFn(cmd *cobra.Command, args []string) error {
 
 	set := args[0]
 
-	fmt.Printf("Removing device %s from set %s...\n", deviceID, set)
+	fmt.Fprintf(cmd.ErrOrStderr(), "Removing device %s from set %s...\n", deviceID, set)
 
-	resp, err := addOrDeletedeviceItem("remove", deviceID, set)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-
-	defer resp.Body.Close()
-	switch resp.StatusCode {
-	case http.StatusNotModified:
-		fmt.Printf("%s is not in set: %s\n", deviceID, set)
-	case http.StatusNoContent:
-		fmt.Printf("%s has been removed from %s\n", deviceID, set)
-	default:
-		if resp.StatusCode == http.StatusInternalServerError {
-			return fmt.Errorf("Set does not exist\n")
-		}
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalln(err)
-		}
-		return fmt.Errorf(string(body))
-	}
-
-	return nil
-}
-
-// addOrDeletedeviceItem handles http for add and remove, probably better to just duplicate the code. Oh well.
-func addOrDeletedeviceItem(action, deviceID, set string) (*http.Response, error) {
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return nil, err
-	}
-	// Device path for the enrollment set
-	ddmUrl.Path = path.Join(ddmUrl.Path, "enrollment-sets", deviceID)
-	// Add the query arguments
-	q := ddmUrl.Query()
-	q.Set("set", set)
-	ddmUrl.RawQuery = q.Encode()
-	var resp *http.Response
-	var respErr error
-	if action == "add" {
-		respErr = putReq(ddmUrl.String(), &resp)
-	} else if action == "remove" {
-		respErr = deleteReq(ddmUrl.String(), &resp)
-	}
-	if respErr != nil {
-		return nil, respErr
-	}
-	return resp, nil
+	return utils.Apply(cmd.Context(), cmd.OutOrStdout(), utils.Task{
+		Resource:         "enrollment-set",
+		Action:           "remove",
+		Name:             fmt.Sprintf("%s in set %s", deviceID, set),
+		Message:          fmt.Sprintf("%s has been removed from %s", deviceID, set),
+		UnchangedMessage: fmt.Sprintf("%s is not in set: %s", deviceID, set),
+		Outcome:          utils.OutcomeDeleted,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			return client.RemoveEnrollmentSet(ctx, deviceID, set)
+		},
+	})
 }
 
 func declarationStatusCmd() *cobra.Command {
@@ -236,36 +181,24 @@ func valuesCmd() *cobra.Command {
 // StatusFn handles all logic for the various status commands
 func StatusFn(cmd *cobra.Command, statuss []string) error {
 	clientID := viper.GetString("client_id")
-	ddmUrl, err := utils.GetDDMUrl()
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
+	var status map[string]any
 	cmdVerb := strings.Split(cmd.Use, " ")[0]
 	switch cmdVerb {
 	case "declarations":
-		ddmUrl.Path = path.Join(ddmUrl.Path, "declaration-status", clientID)
+		status, err = client.DeclarationStatus(cmd.Context(), clientID)
 	case "values":
-		ddmUrl.Path = path.Join(ddmUrl.Path, "status-values", clientID)
+		status, err = client.StatusValues(cmd.Context(), clientID)
 	case "errors":
-		ddmUrl.Path = path.Join(ddmUrl.Path, "status-errors", clientID)
+		status, err = client.StatusErrors(cmd.Context(), clientID)
 	default:
 		return fmt.Errorf("%s is not a valid status type", cmdVerb)
 	}
-	var resp *http.Response
-	err = getReq(ddmUrl.String(), &resp)
-	if err != nil {
-		return err
-	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	var jsonResponse interface{}
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.Result{Data: status, KeyColumn: "enrollment"})
 }
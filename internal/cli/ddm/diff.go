@@ -0,0 +1,186 @@
+package ddm
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/spf13/cobra"
+
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/output"
+	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// Diff statuses
+const (
+	diffNew       = "new"
+	diffChanged   = "changed"
+	diffUnchanged = "unchanged"
+)
+
+// diffResult compares one declaration file with the server's copy
+type diffResult struct {
+	Identifier string               `json:"identifier"`
+	File       string               `json:"file"`
+	Status     string               `json:"status"`
+	Changes    []declaration.Change `json:"changes"`
+
+	server, local map[string]any
+}
+
+// diffDeclarationCmd compares declaration files with the server
+func diffDeclarationCmd() *cobra.Command {
+	diffCmd := &cobra.Command{
+		Use:   "diff /path/to/declaration.json|/path/to/directory",
+		Short: "Show how declaration files differ from the server",
+		Long: `Show how declaration files differ from the server, that is what create or sync
+would change. ServerToken is ignored. Exits 1 when there are differences.`,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: utils.ApplyPreExecFn,
+		RunE:    diffDeclarationFn,
+	}
+
+	diffCmd.Flags().BoolP("structural", "s", false, "List changed fields instead of a unified diff")
+	addValuesFlags(diffCmd)
+
+	return diffCmd
+}
+
+func diffDeclarationFn(cmd *cobra.Command, args []string) error {
+	structural, err := cmd.Flags().GetBool("structural")
+	if err != nil {
+		return err
+	}
+	paths, err := declarationPaths(args[0])
+	if err != nil {
+		return err
+	}
+	values, err := templateValues(cmd)
+	if err != nil {
+		return err
+	}
+	files, err := declaration.ReadFiles(paths, declaration.ReadOptions{Values: values, SkipValidation: true})
+	if err != nil {
+		return err
+	}
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	var results []diffResult
+	differ := 0
+	for _, file := range files {
+		res, err := diffDeclaration(cmd, client, file)
+		if err != nil {
+			return err
+		}
+		results = append(results, res)
+		if res.Status == diffUnchanged {
+			continue
+		}
+		differ++
+		if output.Requested() {
+			continue
+		}
+		if structural {
+			printChanges(cmd, res)
+		} else {
+			printUnifiedDiff(cmd, res)
+		}
+	}
+
+	if output.Requested() {
+		rows := make([][]string, len(results))
+		for i, res := range results {
+			rows[i] = []string{res.Identifier, res.File, res.Status, strconv.Itoa(len(res.Changes))}
+		}
+		if results == nil {
+			results = []diffResult{}
+		}
+		err := output.Print(cmd.OutOrStdout(), output.Result{
+			Data:    results,
+			Columns: []string{"identifier", "file", "status", "changes"},
+			Rows:    rows,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if differ > 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "%d of %d declarations differ from the server\n", differ, len(results))
+		return utils.ErrDifferences
+	}
+	return nil
+}
+
+// declarationPaths returns path, or the declaration files below it if it is a
+// directory
+func declarationPaths(path string) ([]string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{path}, nil
+	}
+	paths, _, err := walkDir(path)
+	return paths, err
+}
+
+func diffDeclaration(cmd *cobra.Command, client *nanohub.Client, file declaration.File) (diffResult, error) {
+	local, err := declaration.Normalize(file.JSON)
+	if err != nil {
+		return diffResult{}, fmt.Errorf("%s: invalid JSON: %w", file.Path, err)
+	}
+	id, _ := local["Identifier"].(string)
+	if id == "" {
+		return diffResult{}, fmt.Errorf("%s: declaration has no Identifier", file.Path)
+	}
+	res := diffResult{Identifier: id, File: file.Path, local: local}
+
+	decl, err := client.GetDeclaration(cmd.Context(), id)
+	if nanohub.StatusCode(err) == http.StatusNotFound {
+		res.Status = diffNew
+		res.Changes = declaration.Compare(nil, local)
+		return res, nil
+	} else if err != nil {
+		return diffResult{}, err
+	}
+	serverJSON, err := json.Marshal(decl)
+	if err != nil {
+		return diffResult{}, err
+	}
+	if res.server, err = declaration.Normalize(serverJSON); err != nil {
+		return diffResult{}, err
+	}
+	res.Changes = declaration.Compare(res.server, local)
+	res.Status = diffChanged
+	if len(res.Changes) == 0 {
+		res.Status = diffUnchanged
+		res.Changes = []declaration.Change{}
+	}
+	return res, nil
+}
+
+func printUnifiedDiff(cmd *cobra.Command, res diffResult) {
+	serverName := "server/" + res.Identifier
+	if res.Status == diffNew {
+		serverName = "/dev/null"
+	}
+	fmt.Fprint(cmd.OutOrStdout(), declaration.UnifiedDiff(serverName, res.File, declaration.Indent(res.server), declaration.Indent(res.local)))
+}
+
+func printChanges(cmd *cobra.Command, res diffResult) {
+	fmt.Fprintf(cmd.OutOrStdout(), "%s (%s, %s)\n", res.Identifier, res.File, res.Status)
+	if res.Status == diffNew {
+		return
+	}
+	for _, change := range res.Changes {
+		fmt.Fprintf(cmd.OutOrStdout(), "  %s\n", change)
+	}
+}
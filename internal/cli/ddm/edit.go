@@ -0,0 +1,202 @@
+package ddm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/output"
+	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// editHeader starts the comment block the edit command adds to the file
+const editHeader = `# Edit the declaration below and save to upload it. Lines at the top
+# starting with '#' are ignored, and an empty file cancels the edit.
+`
+
+// editDeclarationCmd edits a declaration on the server in $EDITOR
+func editDeclarationCmd() *cobra.Command {
+	editCmd := &cobra.Command{
+		Use:   "edit com.example.declaration",
+		Short: "Edit a declaration on the server in $EDITOR",
+		Long: `Fetch a declaration, open it in $VISUAL or $EDITOR, and upload it again if it
+changed. The edited declaration is validated first and the editor reopens with
+the problems listed if it is not valid, unless --skip-validation is given.`,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: utils.ApplyPreExecFn,
+		RunE:    editDeclarationFn,
+	}
+
+	editCmd.Flags().StringP("format", "F", declaration.JSON, "Format to edit the declaration in: json or yaml")
+	editCmd.Flags().Bool("skip-validation", false, "Upload without checking the declaration against its schema first, e.g. for types go-adm does not know")
+
+	return editCmd
+}
+
+func editDeclarationFn(cmd *cobra.Command, args []string) error {
+	identifier := args[0]
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if format != declaration.JSON && format != declaration.YAML {
+		return utils.NewUsageError("unknown format %q, valid formats are: json, yaml", format)
+	}
+	skipValidation, err := cmd.Flags().GetBool("skip-validation")
+	if err != nil {
+		return err
+	}
+
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+	decl, err := client.GetDeclaration(cmd.Context(), identifier)
+	if err != nil {
+		return err
+	}
+	serverJSON, err := json.Marshal(decl)
+	if err != nil {
+		return err
+	}
+	server, err := declaration.Normalize(serverJSON)
+	if err != nil {
+		return err
+	}
+	original, err := declaration.FromJSON(format, []byte(declaration.Indent(server)))
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp("", "nanohubctl-*."+format)
+	if err != nil {
+		return err
+	}
+	tmp.Close()
+	defer os.Remove(tmp.Name())
+
+	content := original
+	header := editHeader
+	var edited map[string]any
+	for {
+		if err := os.WriteFile(tmp.Name(), append([]byte(header), content...), 0600); err != nil {
+			return err
+		}
+		if err := runEditor(cmd.Context(), tmp.Name()); err != nil {
+			return err
+		}
+		b, err := os.ReadFile(tmp.Name())
+		if err != nil {
+			return err
+		}
+		content = stripHeader(b)
+		if len(bytes.TrimSpace(content)) == 0 || bytes.Equal(content, original) {
+			fmt.Fprintln(cmd.ErrOrStderr(), "Edit cancelled, no changes made.")
+			return nil
+		}
+
+		edited, err = parseEdited(format, identifier, content, skipValidation)
+		if err == nil {
+			break
+		}
+		// Reopen the editor with the problems listed at the top
+		header = editHeader + "#\n# The declaration is not valid:\n"
+		for _, line := range strings.Split(err.Error(), "\n") {
+			header += "#   " + line + "\n"
+		}
+	}
+
+	if diff := declaration.UnifiedDiff("server/"+identifier, "edited/"+identifier, declaration.Indent(server), declaration.Indent(edited)); diff == "" {
+		fmt.Fprintln(cmd.ErrOrStderr(), "No changes made.")
+		return nil
+	} else if !output.Requested() {
+		fmt.Fprint(cmd.OutOrStdout(), diff)
+	}
+
+	editedJSON, err := json.Marshal(edited)
+	if err != nil {
+		return err
+	}
+	return utils.Apply(cmd.Context(), cmd.OutOrStdout(), utils.Task{
+		Resource:         "declaration",
+		Action:           "put",
+		Name:             identifier,
+		Message:          fmt.Sprintf("%s has been updated", identifier),
+		UnchangedMessage: fmt.Sprintf("%s is unchanged", identifier),
+		Outcome:          utils.OutcomeUpdated,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			return client.PutDeclaration(ctx, editedJSON)
+		},
+	})
+}
+
+// parseEdited converts and, unless skipValidation is set, validates the
+// edited declaration
+func parseEdited(format, identifier string, content []byte, skipValidation bool) (map[string]any, error) {
+	declJSON, err := declaration.ToJSON(format, content)
+	if err != nil {
+		return nil, fmt.Errorf("invalid %s: %w", format, err)
+	}
+	if !skipValidation {
+		if err := declaration.Validate(identifier, declJSON); err != nil {
+			return nil, err
+		}
+	}
+	edited, err := declaration.Normalize(declJSON)
+	if err != nil {
+		return nil, err
+	}
+	if id, _ := edited["Identifier"].(string); id != identifier {
+		return nil, fmt.Errorf("Identifier cannot be changed from %s, use declaration create for a new declaration", identifier)
+	}
+	return edited, nil
+}
+
+// stripHeader drops the comment lines at the top of the edited file
+func stripHeader(b []byte) []byte {
+	for len(b) > 0 && b[0] == '#' {
+		i := bytes.IndexByte(b, '\n')
+		if i < 0 {
+			return nil
+		}
+		b = b[i+1:]
+	}
+	return b
+}
+
+// runEditor opens path in $VISUAL or $EDITOR, vi or notepad if neither is set.
+// The editor command may include arguments, e.g. "code --wait".
+func runEditor(ctx context.Context, path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		if editor == "" {
+			editor = "notepad"
+		}
+		cmd = exec.CommandContext(ctx, "cmd", "/C", editor+` "`+path+`"`)
+	} else {
+		if editor == "" {
+			editor = "vi"
+		}
+		cmd = exec.CommandContext(ctx, "sh", "-c", editor+` "$1"`, "sh", path)
+	}
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("editor %q failed: %w", editor, err)
+	}
+	return nil
+}
@@ -0,0 +1,120 @@
+package ddm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/utils"
+)
+
+// exportCmd writes every declaration and set on the server to a directory
+func exportCmd() *cobra.Command {
+	exportCmd := &cobra.Command{
+		Use:   "export /path/to/directory",
+		Short: "Export all declarations and sets into a directory sync can read",
+		Long: `Export all declarations and sets on the server into a directory in the layout
+sync reads: <identifier>.json for each declaration, without its ServerToken,
+and set.<name>.txt listing the declarations of each set.`,
+		Args:    cobra.ExactArgs(1),
+		PreRunE: utils.ApplyPreExecFn,
+		RunE:    exportFn,
+	}
+
+	exportCmd.Flags().StringP("format", "F", declaration.JSON, "Format to write declarations in: json, yaml or plist")
+	exportCmd.Flags().BoolP("force", "f", false, "Write into a directory that is not empty, overwriting files")
+
+	return exportCmd
+}
+
+func exportFn(cmd *cobra.Command, args []string) error {
+	dirPath := args[0]
+	format, err := cmd.Flags().GetString("format")
+	if err != nil {
+		return err
+	}
+	if !declaration.ValidFormat(format) {
+		return utils.NewUsageError("unknown format %q, valid formats are: %s", format, strings.Join(declaration.Formats, ", "))
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+	if entries, err := os.ReadDir(dirPath); err == nil && len(entries) > 0 && !force {
+		return fmt.Errorf("directory %s is not empty, use --force to write into it anyway", dirPath)
+	}
+	if err := os.MkdirAll(dirPath, 0755); err != nil {
+		return err
+	}
+
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+	identifiers, err := client.ListDeclarations(cmd.Context())
+	if err != nil {
+		return err
+	}
+	err = utils.ForEach(cmd.Context(), viper.GetInt("concurrency"), len(identifiers), func(ctx context.Context, i int) error {
+		decl, err := client.GetDeclaration(ctx, identifiers[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", identifiers[i], err)
+		}
+		declJSON, err := json.Marshal(decl)
+		if err != nil {
+			return err
+		}
+		normalized, err := declaration.Normalize(declJSON)
+		if err != nil {
+			return err
+		}
+		if declJSON, err = json.MarshalIndent(normalized, "", "\t"); err != nil {
+			return err
+		}
+		b, err := declaration.FromJSON(format, append(declJSON, '\n'))
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(filepath.Join(dirPath, exportFileName(identifiers[i])+"."+format), b, 0644)
+	})
+	if err != nil {
+		return err
+	}
+
+	sets, err := client.ListSets(cmd.Context())
+	if err != nil {
+		return err
+	}
+	for _, set := range sets {
+		ids, err := client.SetDeclarations(cmd.Context(), set)
+		if err != nil {
+			return err
+		}
+		var b strings.Builder
+		for _, id := range ids {
+			b.WriteString(id + "\n")
+		}
+		path := filepath.Join(dirPath, "set."+exportFileName(set)+".txt")
+		if err := os.WriteFile(path, []byte(b.String()), 0644); err != nil {
+			return err
+		}
+		if name := setNameFromPath(path); name != set {
+			fmt.Fprintf(cmd.ErrOrStderr(), "Warning: set %s will be synced back as %s\n", set, name)
+		}
+	}
+
+	fmt.Fprintf(cmd.OutOrStdout(), "Exported %d declarations and %d sets to %s\n", len(identifiers), len(sets), dirPath)
+	return nil
+}
+
+// exportFileName makes an identifier or set name safe to use as a file name
+func exportFileName(name string) string {
+	return strings.NewReplacer("/", "_", "\\", "_", ":", "_").Replace(name)
+}
@@ -23,6 +23,7 @@ func RootCmd() *cobra.Command {
 		setCmd(),
 		deviceCmd(),
 		syncCmd(),
+		exportCmd(),
 		tokenDdmCmd(),
 		declarationItemsCmd(),
 	)
@@ -1,17 +1,16 @@
 package ddm
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"path"
-
-	"net/http"
 
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 
+	"github.com/macadmins/nanohubctl/internal/output"
 	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 )
 
 // setCmd handles creation and management of declaration sets
@@ -52,29 +51,15 @@ func listSetsCmd() *cobra.Command {
 }
 
 func listSetsFn(cmd *cobra.Command, args []string) error {
-	fmt.Printf("Listing all available sets\n")
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return err
-	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "sets")
-	var resp *http.Response
-	err = getReq(ddmUrl.String(), &resp)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	sets, err := client.ListSets(cmd.Context())
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	var jsonResponse []string
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.List("name", sets))
 }
 
 // getCmd handles getting sets on the server
@@ -93,33 +78,18 @@ func getSetCmd() *cobra.Command {
 
 func getSetFn(cmd *cobra.Command, args []string) error {
 	name := args[0]
-	fmt.Printf("Getting set for identifier %s\n\n", name)
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return err
-	}
-	ddmUrl.Path = path.Join(ddmUrl.Path, "set-declarations", name)
-	var resp *http.Response
-	err = getReq(ddmUrl.String(), &resp)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	defer resp.Body.Close()
-	body, err := io.ReadAll(resp.Body)
+	identifiers, err := client.SetDeclarations(cmd.Context(), name)
 	if err != nil {
 		return err
 	}
-	resp.Body.Close()
-	var jsonResponse []string
-	if err := json.Unmarshal(body, &jsonResponse); err != nil {
-		return err
-	}
-	if jsonResponse == nil {
-		fmt.Println("No declarations found")
-		return nil
+	if len(identifiers) == 0 {
+		fmt.Fprintf(cmd.ErrOrStderr(), "No declarations found in set %s\n", name)
 	}
-	fmt.Println(utils.PrettyJsonPrint(jsonResponse))
-	return nil
+	return output.Print(cmd.OutOrStdout(), output.List("identifier", identifiers))
 }
 
 // addSetCmd adds a declaration to a given set
@@ -143,46 +113,41 @@ func addSetCmd() *cobra.Command {
 
 func addSetFn(cmd *cobra.Command, args []string) error {
 	name, err := cmd.Flags().GetString("name")
+	if err != nil {
+		return err
+	}
 	identifier, err := cmd.Flags().GetString("identifier")
 	if err != nil {
 		return err
 	}
-	err = addSet(name, identifier)
+	client, err := utils.NewClient(cmd.Context())
 	if err != nil {
 		return err
 	}
-	return nil
+	results, err := addSet(cmd.Context(), client, name, identifier)
+	return errors.Join(utils.PrintResults(cmd.OutOrStdout(), results), err)
 }
 
-func addSet(name string, identifier ...string) error {
-	for _, decl_id := range identifier {
-		// fmt.Printf("\nAdding %s to set %s...\n", decl_id, name)
-
-		resp, err := addOrDeleteSetItem("add", name, decl_id)
-		if err != nil {
-			return err
-		}
-
-		defer resp.Body.Close()
-		switch resp.StatusCode {
-		case http.StatusNotModified:
-			// fmt.Printf("%s is already in %s\n", decl_id, name)
-		case http.StatusNoContent:
-			fmt.Printf("%s has been added to set: %s\n", decl_id, name)
-		default:
-			fmt.Println(resp.Status)
-			_, err := io.ReadAll(resp.Body)
-			if err != nil {
-				log.Fatalln(err)
-				fmt.Println("Error adding declaration to set:", decl_id, "in", name)
-			}
-			fmt.Println("Error adding declaration to set:", decl_id, "in", name)
-			continue
-			// return fmt.Errorf(string(body))
-		}
+func addSet(ctx context.Context, client *nanohub.Client, name string, identifier ...string) ([]utils.Result, error) {
+	return utils.NewBulk("add declarations to set "+name, viper.GetInt("concurrency")).Run(ctx, addSetTasks(client, name, identifier...))
+}
 
-	}
-	return nil
+// addSetTasks returns a task per declaration to add to the set name
+func addSetTasks(client *nanohub.Client, name string, identifier ...string) []utils.Task {
+	var tasks []utils.Task
+	for _, decl_id := range identifier {
+		tasks = append(tasks, utils.Task{
+			Resource: "set-declaration",
+			Action:   "add",
+			Name:     fmt.Sprintf("%s in set %s", decl_id, name),
+			Message:  fmt.Sprintf("%s has been added to set: %s", decl_id, name),
+			Outcome:  utils.OutcomeCreated,
+			Do: func(ctx context.Context) (nanohub.Change, error) {
+				return client.AddSetDeclaration(ctx, name, decl_id)
+			},
+		})
+	}
+	return tasks
 }
 
 // deleteSetCmd deletes a declaration from a given set
@@ -205,55 +170,33 @@ func deleteSetCmd() *cobra.Command {
 
 func deleteSetFn(cmd *cobra.Command, sets []string) error {
 	name, err := cmd.Flags().GetString("name")
-	identifier, err := cmd.Flags().GetString("identifier")
 	if err != nil {
 		return err
 	}
-
-	resp, err := addOrDeleteSetItem("delete", name, identifier)
+	identifier, err := cmd.Flags().GetString("identifier")
 	if err != nil {
 		return err
 	}
-
-	defer resp.Body.Close()
-	switch resp.StatusCode {
-	case http.StatusNotModified:
-		fmt.Printf("%s does not exist in %s", identifier, name)
-	case http.StatusNoContent:
-		fmt.Printf("%s has been removed from set: %s", identifier, name)
-	default:
-		fmt.Println(resp.Status)
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			log.Fatalln(err)
-		}
-		return fmt.Errorf(string(body))
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
 	}
 
-	return nil
+	return utils.Apply(cmd.Context(), cmd.OutOrStdout(), removeSetTask(client, name, identifier))
 }
 
-// addOrDeleteSetItem handles http for add and remove, probably better to just duplicate the code.
-func addOrDeleteSetItem(action, name, identifier string) (*http.Response, error) {
-	ddmUrl, err := utils.GetDDMUrl()
-	if err != nil {
-		return nil, err
-	}
-	// Set the path
-	ddmUrl.Path = path.Join(ddmUrl.Path, "/set-declarations/", name)
-	// Add the query arguments
-	q := ddmUrl.Query()
-	q.Set("declaration", identifier)
-	ddmUrl.RawQuery = q.Encode()
-	var resp *http.Response
-	var respErr error
-	if action == "add" {
-		respErr = putReq(ddmUrl.String(), &resp)
-	} else if action == "delete" {
-		respErr = deleteReq(ddmUrl.String(), &resp)
-	}
-	if respErr != nil {
-		return nil, respErr
+// removeSetTask returns the task removing the declaration identifier from
+// the set name
+func removeSetTask(client *nanohub.Client, name, identifier string) utils.Task {
+	return utils.Task{
+		Resource:         "set-declaration",
+		Action:           "remove",
+		Name:             fmt.Sprintf("%s in set %s", identifier, name),
+		Message:          fmt.Sprintf("%s has been removed from set: %s", identifier, name),
+		UnchangedMessage: fmt.Sprintf("%s does not exist in %s", identifier, name),
+		Outcome:          utils.OutcomeDeleted,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			return client.RemoveSetDeclaration(ctx, name, identifier)
+		},
 	}
-	return resp, nil
 }
@@ -2,24 +2,46 @@ package ddm
 
 import (
 	"bufio"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"maps"
+	"net/http"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/output"
 	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
 )
 
 func syncCmd() *cobra.Command {
 	syncDirCmd := &cobra.Command{
-		Use:     "sync /path/to/directory",
-		Short:   "Sync directory with DDM",
-		Long:    "Sync directory with DDM",
+		Use:   "sync /path/to/directory",
+		Short: "Sync directory with DDM",
+		Long: `Sync directory with DDM. Declarations are uploaded and added to their sets
+in dependency order: assets, then configurations, management and activations,
+and within a class after the declarations they refer to, so devices never see a
+set with unresolved references. A declaration is not uploaded or added to a set
+when one it refers to failed.
+
+With --prune, declarations on the server that a set file no longer lists are
+removed from the set afterwards, in reverse order. --dry-run prints the changes
+without making them.`,
 		Args:    cobra.ExactArgs(1),
 		PreRunE: utils.ApplyPreExecFn,
 		RunE:    syncDirFn,
 	}
+	syncDirCmd.Flags().Bool("skip-validation", false, "Upload without checking declarations against their schema first")
+	syncDirCmd.Flags().Bool("prune", false, "Remove declarations that set files do not list from their sets")
+	syncDirCmd.Flags().Bool("dry-run", false, "Print what would be done without changing anything")
+	addValuesFlags(syncDirCmd)
 	return syncDirCmd
 }
 
@@ -31,62 +53,245 @@ func syncDirFn(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("directory %s does not exist", dirPath)
 	}
 
-	// Collect all JSON file paths
-	var declJSONPaths []string
-	var setPaths []string
+	declJSONPaths, setPaths, err := walkDir(dirPath)
+	if err != nil {
+		return err
+	}
+	skipValidation, err := cmd.Flags().GetBool("skip-validation")
+	if err != nil {
+		return err
+	}
+	prune, err := cmd.Flags().GetBool("prune")
+	if err != nil {
+		return err
+	}
+	dryRun, err := cmd.Flags().GetBool("dry-run")
+	if err != nil {
+		return err
+	}
+	values, err := templateValues(cmd)
+	if err != nil {
+		return err
+	}
+	files, err := declaration.ReadFiles(declJSONPaths, declaration.ReadOptions{Values: values, SkipValidation: skipValidation})
+	if err != nil {
+		return err
+	}
+	declSets, err := readSetFiles(setPaths, values)
+	if err != nil {
+		return err
+	}
+	for setName, identifiers := range declSets {
+		if len(identifiers) == 0 {
+			fmt.Fprintf(os.Stderr, "No identifiers found for set %s, skipping...\n", setName)
+			delete(declSets, setName)
+		}
+	}
+	client, err := utils.NewClient(cmd.Context())
+	if err != nil {
+		return err
+	}
+
+	stages, err := syncStages(cmd.Context(), client, files, declSets, prune)
+	if err != nil {
+		return err
+	}
+	if dryRun {
+		return utils.Plan(cmd.OutOrStdout(), slices.Concat(stages...)...)
+	}
+	results, err := utils.NewBulk("sync", viper.GetInt("concurrency")).RunStages(cmd.Context(), stages)
+	if err := errors.Join(utils.PrintResults(cmd.OutOrStdout(), results), err); err != nil {
+		return err
+	}
+	if !output.Requested() {
+		for setName, items := range declSets {
+			fmt.Printf("Synced %d declarations in set '%s'\n", len(items), setName)
+		}
+		fmt.Printf("Synced %d declarations to NanoHUB\n", len(declJSONPaths))
+	}
+	return nil
+}
+
+// syncStages orders the uploads and set changes of a sync by the reference
+// graph of the declarations, see declaration.Graph.Stages. Each stage of
+// declarations is uploaded and then added to its sets. Removals from sets
+// with prune come last, in reverse order, and only once every declaration of
+// the set was applied.
+func syncStages(ctx context.Context, client *nanohub.Client, files []declaration.File, declSets map[string][]string, prune bool) ([][]utils.Task, error) {
+	existing, err := client.ListDeclarations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	decls := map[string]map[string]any{}
+	filesByID := map[string][]declaration.File{}
+	// Files without an identifier are left to the server to reject
+	var unnamed []declaration.File
+	for _, file := range files {
+		decl, err := declaration.Normalize(file.JSON)
+		id, _ := decl["Identifier"].(string)
+		if err != nil || id == "" {
+			unnamed = append(unnamed, file)
+			continue
+		}
+		decls[id] = decl
+		filesByID[id] = append(filesByID[id], file)
+	}
+
+	// Members of the sets on the server that the set files no longer list
+	stale := map[string][]string{}
+	if prune {
+		for setName, identifiers := range declSets {
+			members, err := client.SetDeclarations(ctx, setName)
+			if err != nil && nanohub.StatusCode(err) != http.StatusNotFound {
+				return nil, err
+			}
+			for _, id := range members {
+				if !slices.Contains(identifiers, id) {
+					stale[setName] = append(stale[setName], id)
+				}
+			}
+		}
+	}
+
+	// Set members without a file are ordered by their type on the server
+	var ids, fetch []string
+	for id := range decls {
+		ids = append(ids, id)
+	}
+	for _, members := range []map[string][]string{declSets, stale} {
+		for _, identifiers := range members {
+			for _, id := range identifiers {
+				if slices.Contains(ids, id) {
+					continue
+				}
+				ids = append(ids, id)
+				if slices.Contains(existing, id) {
+					fetch = append(fetch, id)
+				}
+			}
+		}
+	}
+	fetched := make([]map[string]any, len(fetch))
+	err = utils.ForEach(ctx, viper.GetInt("concurrency"), len(fetch), func(ctx context.Context, i int) error {
+		decl, err := client.GetDeclaration(ctx, fetch[i])
+		if err != nil {
+			return fmt.Errorf("%s: %w", fetch[i], err)
+		}
+		fetched[i], err = normalizeServer(decl)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i, id := range fetch {
+		decls[id] = fetched[i]
+	}
 
-	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+	graph := declaration.NewGraph(decls, declSets)
+	order := graph.Stages(ids)
+	setNames := slices.Sorted(maps.Keys(declSets))
+	var stages [][]utils.Task
+	for _, stage := range order {
+		var puts, adds []utils.Task
+		for _, id := range stage {
+			var needs []string
+			for _, ref := range graph.References[id] {
+				needs = append(needs, ref.To)
+			}
+			for _, file := range filesByID[id] {
+				task := putDeclarationTask(client, file, existing)
+				task.Needs = needs
+				puts = append(puts, task)
+			}
+			for _, setName := range setNames {
+				if !slices.Contains(declSets[setName], id) {
+					continue
+				}
+				task := addSetTasks(client, setName, id)[0]
+				task.Key = id
+				task.Needs = append(slices.Clone(needs), id)
+				adds = append(adds, task)
+			}
+		}
+		stages = appendStage(stages, puts)
+		stages = appendStage(stages, adds)
+	}
+	var puts []utils.Task
+	for _, file := range unnamed {
+		puts = append(puts, putDeclarationTask(client, file, existing))
+	}
+	stages = appendStage(stages, puts)
+
+	for _, stage := range slices.Backward(order) {
+		var removes []utils.Task
+		for _, id := range stage {
+			for _, setName := range setNames {
+				if !slices.Contains(stale[setName], id) {
+					continue
+				}
+				task := removeSetTask(client, setName, id)
+				task.Needs = declSets[setName]
+				removes = append(removes, task)
+			}
+		}
+		stages = appendStage(stages, removes)
+	}
+	return stages, nil
+}
+
+func appendStage(stages [][]utils.Task, stage []utils.Task) [][]utils.Task {
+	if len(stage) == 0 {
+		return stages
+	}
+	return append(stages, stage)
+}
+
+// walkDir collects the declaration files and set files below dirPath
+func walkDir(dirPath string) (declPaths, setPaths []string, err error) {
+	err = filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
 
-		// Skip directories and non-JSON files
+		// Skip directories and non-declaration files. Hidden directories such
+		// as .git and .github hold no declarations, but may hold YAML.
 		if info.IsDir() {
+			if path != dirPath && strings.HasPrefix(info.Name(), ".") {
+				return filepath.SkipDir
+			}
 			return nil
 		}
-		if strings.HasSuffix(strings.ToLower(path), ".json") {
-			declJSONPaths = append(declJSONPaths, path)
+		if declaration.IsFile(path) {
+			declPaths = append(declPaths, path)
 			return nil
 		}
 		// Match files that start with the word "set" and end with ".txt"
 		if strings.HasSuffix(path, ".txt") && strings.HasPrefix(filepath.Base(path), "set") {
-			fmt.Printf("Processing %s\n", path)
 			setPaths = append(setPaths, path)
 		}
-
-		if err != nil {
-			return fmt.Errorf("error walking directory: %v", err)
-		}
-
 		return nil
 	})
-	if err != nil {
-		return err
-	}
-	err = createDeclaration(declJSONPaths...)
-	if err != nil {
-		return err
-	}
-	err = syncSets(setPaths)
-	if err != nil {
-		return nil
-	}
-	fmt.Printf("Synced %d declarations to NanoHUB\n", len(declJSONPaths))
-	return nil
+	return declPaths, setPaths, err
 }
 
-func syncSets(setPaths []string) error {
+// readSetFiles reads the identifiers listed in set files, rendered with
+// values like declaration files, keyed by set name
+func readSetFiles(setPaths []string, values map[string]any) (map[string][]string, error) {
 	declSets := make(map[string][]string)
 	for _, setPath := range setPaths {
+		fmt.Fprintf(os.Stderr, "Processing %s\n", setPath)
 		setName := setNameFromPath(setPath)
 		declSets[setName] = []string{}
-		file, err := os.Open(setPath)
+		b, err := os.ReadFile(setPath)
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if b, err = declaration.Render(setPath, b, values); err != nil {
+			return nil, err
 		}
-		defer file.Close()
 
-		scanner := bufio.NewScanner(file)
+		scanner := bufio.NewScanner(bytes.NewReader(b))
 
 		for scanner.Scan() {
 			line := scanner.Text()
@@ -97,24 +302,10 @@ func syncSets(setPaths []string) error {
 		}
 
 		if err := scanner.Err(); err != nil {
-			fmt.Printf("Error reading file: %v\n", err)
+			fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
 		}
 	}
-	// Now process the declaratiosn for each set
-	for setName, identifiers := range declSets {
-		if len(identifiers) == 0 {
-			fmt.Printf("No identifiers found for set %s, skipping...\n", setName)
-			continue
-		}
-		err := addSet(setName, identifiers...)
-		if err != nil {
-			return err
-		}
-	}
-	for setName, items := range declSets {
-		fmt.Printf("Synced %d declarations in set '%s'\n", len(items), setName)
-	}
-	return nil
+	return declSets, nil
 }
 
 // Derive set name from file name and normalize it
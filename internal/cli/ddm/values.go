@@ -0,0 +1,92 @@
+package ddm
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v3"
+
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/utils"
+)
+
+// addValuesFlags adds the flags supplying template values for declaration
+// files
+func addValuesFlags(cmd *cobra.Command) {
+	cmd.Flags().String("values", "", "YAML or JSON file of values for templated declaration files")
+	cmd.Flags().StringArray("set", nil, "Set a template value, key=value (can be repeated)")
+}
+
+// templateValues returns the values declaration files are rendered with:
+// values from the config file and the active profile, overridden by the
+// --values file, overridden by --set
+func templateValues(cmd *cobra.Command) (map[string]any, error) {
+	values := map[string]any{}
+
+	cf, err := utils.ReadConfigFile()
+	if err != nil {
+		return nil, err
+	}
+	if global, ok := configValues(cf.Settings["values"]).(map[string]any); ok {
+		declaration.MergeValues(values, global)
+	}
+	if name := utils.ActiveProfile(); name != "" {
+		instances, err := utils.ReadInstances()
+		if err != nil {
+			return nil, err
+		}
+		if profile, ok := configValues(utils.AllProfiles(cf, instances)[name]["values"]).(map[string]any); ok {
+			declaration.MergeValues(values, profile)
+		}
+	}
+
+	path, err := cmd.Flags().GetString("values")
+	if err != nil {
+		return nil, err
+	}
+	if path != "" {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		var fileValues map[string]any
+		if err := yaml.Unmarshal(b, &fileValues); err != nil {
+			return nil, fmt.Errorf("invalid values file %s: %w", path, err)
+		}
+		declaration.MergeValues(values, fileValues)
+	}
+
+	assignments, err := cmd.Flags().GetStringArray("set")
+	if err != nil {
+		return nil, err
+	}
+	for _, assignment := range assignments {
+		if err := declaration.SetValue(values, assignment); err != nil {
+			return nil, &utils.UsageError{Err: err}
+		}
+	}
+	return values, nil
+}
+
+// configValues converts values from the config file to plain maps. Maps
+// nested in a profile are decoded as utils.Profile.
+func configValues(v any) any {
+	switch v := v.(type) {
+	case utils.Profile:
+		return configValues(map[string]any(v))
+	case map[string]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			m[k] = configValues(item)
+		}
+		return m
+	case []any:
+		items := make([]any, len(v))
+		for i, item := range v {
+			items[i] = configValues(item)
+		}
+		return items
+	}
+	return v
+}
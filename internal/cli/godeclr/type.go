@@ -3,29 +3,36 @@ package godeclr
 import (
 	"encoding/json"
 	"fmt"
-	"os"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/korylprince/go-adm/declarations"
 	"github.com/korylprince/go-adm/tagutil"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+
+	"github.com/macadmins/nanohubctl/internal/declaration"
+	"github.com/macadmins/nanohubctl/internal/utils"
 )
 
 func TypeCmd() *cobra.Command {
 	typeCmd := &cobra.Command{
-		Use:   "type [declaration type] [-full]",
+		Use:   "type [declaration type] [-full] [-format json|yaml|plist]",
 		Short: "type",
 		Long:  "Show an example of the specified declaration type",
 		RunE: func(cmd *cobra.Command, args []string) error {
 			serverToken := ""
 			if len(args) == 0 {
-				return fmt.Errorf("declaration type must be specified")
+				return utils.NewUsageError("declaration type must be specified")
+			}
+			format := viper.GetString("decl_format")
+			if !declaration.ValidFormat(format) {
+				return utils.NewUsageError("unknown format %q, valid formats are: %s", format, strings.Join(declaration.Formats, ", "))
 			}
 			typ := args[0]
 			_, ok := declarations.DeclarationMap[typ]
 			if !ok {
-				return fmt.Errorf("unknown declaration type: %s", typ)
+				return utils.NewUsageError("unknown declaration type: %s", typ)
 			}
 			decl_identifier := viper.GetString("decl_identifier")
 			if decl_identifier == "" {
@@ -33,15 +40,13 @@ func TypeCmd() *cobra.Command {
 			}
 			decl, err := declarations.NewFromType(typ, decl_identifier, serverToken)
 			if err != nil {
-				fmt.Println("could not generate declaration:", err)
-				os.Exit(1)
+				return fmt.Errorf("could not generate declaration: %w", err)
 			}
 			var declobj any = decl
 			if viper.GetBool("full") {
 				payload := tagutil.FullFields(decl.Payload)
 				if err = tagutil.SetDefaults(payload); err != nil {
-					fmt.Println("could not fill out declaration:", err)
-					os.Exit(1)
+					return fmt.Errorf("could not fill out declaration: %w", err)
 				}
 
 				m := map[string]any{
@@ -57,21 +62,25 @@ func TypeCmd() *cobra.Command {
 			}
 			buf, err := json.MarshalIndent(declobj, "", "\t")
 			if err != nil {
-				fmt.Println("could not json marshal declaration:", err)
-				os.Exit(1)
+				return fmt.Errorf("could not json marshal declaration: %w", err)
+			}
+			if buf, err = declaration.FromJSON(format, buf); err != nil {
+				return fmt.Errorf("could not convert declaration to %s: %w", format, err)
 			}
 
-			fmt.Println(string(buf))
+			fmt.Println(strings.TrimSuffix(string(buf), "\n"))
 			return nil
 		},
 	}
 
 	typeCmd.Flags().StringP("type", "T", "", "declaration type. Use -types to list all supported types")
 	typeCmd.Flags().BoolP("full", "f", false, "output all fields in the declaration")
+	typeCmd.Flags().StringP("format", "F", declaration.JSON, "declaration format: json, yaml or plist")
 	typeCmd.PersistentFlags().StringP("identifier", "I", "", "declaration identifier (auto-generated UUID if not specified)")
 
 	viper.BindPFlag("type", typeCmd.Flags().Lookup("type"))
 	viper.BindPFlag("full", typeCmd.Flags().Lookup("full"))
+	viper.BindPFlag("decl_format", typeCmd.Flags().Lookup("format"))
 	viper.BindPFlag("decl_identifier", typeCmd.PersistentFlags().Lookup("identifier"))
 
 	return typeCmd
@@ -2,7 +2,6 @@ package godeclr
 
 import (
 	"fmt"
-	"os"
 	"sort"
 
 	"github.com/korylprince/go-adm/declarations"
@@ -24,7 +23,6 @@ func TypesCmd() *cobra.Command {
 			for _, typ := range typs {
 				fmt.Println("\t" + typ)
 			}
-			os.Exit(0)
 			return nil
 		},
 	}
@@ -0,0 +1,99 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/spf13/cobra"
+
+	"github.com/macadmins/nanohubctl/internal/utils"
+)
+
+func instanceCmd() *cobra.Command {
+	instanceCmd := &cobra.Command{
+		Use:   "instance [command]",
+		Short: "Manage instances provisioned with new",
+		Long:  "Manage the nanohub instances provisioned with the new command",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := cmd.Help(); err != nil {
+				return err
+			}
+			return nil
+		},
+	}
+
+	instanceCmd.AddCommand(
+		listInstancesCmd(),
+		removeInstanceCmd(),
+	)
+
+	return instanceCmd
+}
+
+// listInstancesCmd lists provisioned instances
+func listInstancesCmd() *cobra.Command {
+	listCmd := &cobra.Command{
+		Use:   "list",
+		Short: "List provisioned instances",
+		Long:  "List provisioned instances and the profile each is available as",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instances, err := utils.ReadInstances()
+			if err != nil {
+				return err
+			}
+			if len(instances) == 0 {
+				fmt.Println("No instances provisioned, create one with: nanohubctl new --token TOKEN")
+				return nil
+			}
+			for _, instance := range instances {
+				fmt.Printf("%-20s %s\n", instance.ProfileName(), instance.URL())
+			}
+			return nil
+		},
+	}
+
+	return listCmd
+}
+
+// removeInstanceCmd forgets a provisioned instance
+func removeInstanceCmd() *cobra.Command {
+	removeCmd := &cobra.Command{
+		Use:   "remove NAME",
+		Short: "Remove a provisioned instance",
+		Long:  "Remove a provisioned instance from nanohubctl by profile or instance name. The instance itself is not deleted from the server.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := args[0]
+			instances, err := utils.ReadInstances()
+			if err != nil {
+				return err
+			}
+			i := slices.IndexFunc(instances, func(i utils.Instance) bool {
+				return i.ProfileName() == name || i.Name == name
+			})
+			if i < 0 {
+				return utils.NewUsageError("no instance named %s", name)
+			}
+			removed := instances[i]
+			if err := utils.WriteInstances(slices.Delete(instances, i, i+1)); err != nil {
+				return err
+			}
+			// Don't leave the config file pointing at a profile that is gone
+			cf, err := utils.ReadConfigFile()
+			if err != nil {
+				return err
+			}
+			if _, ok := cf.Profiles[removed.ProfileName()]; !ok && cf.Profile == removed.ProfileName() {
+				cf.Profile = ""
+				if err := utils.WriteConfigFile(cf); err != nil {
+					return err
+				}
+			}
+			fmt.Printf("Removed instance %s (%s)\n", removed.ProfileName(), removed.URL())
+			return nil
+		},
+	}
+
+	return removeCmd
+}
@@ -1,43 +1,15 @@
 package nanocmd
 
 import (
+	"context"
 	"fmt"
-	"net/http"
-	"net/url"
-	"path"
 
 	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
-// Example URL
-// http://example.com/api/v1/nanocmd/workflow/io.micromdm.wf.devinfolog.v1/start?id=9876-5432-1012
-
-// StartWorkflow posts a workflow to the nanocmd API endpoint
-func StartWorkflow(workflowName, clientID string) (*http.Response, error) {
-	baseUrl, err := utils.GetNanoCMDUrl()
-	if err != nil {
-		return nil, fmt.Errorf("failed to get nanocmd URL: %w", err)
-	}
-
-	// Build the path: /api/v1/nanocmd/workflow/{workflowName}/start
-	baseUrl.Path = path.Join(baseUrl.Path, "workflow", workflowName, "start")
-
-	// Add the client ID as a query parameter
-	params := url.Values{}
-	params.Add("id", clientID)
-	baseUrl.RawQuery = params.Encode()
-
-	var resp *http.Response
-	err = postReq(baseUrl.String(), &resp)
-	if err != nil {
-		return nil, fmt.Errorf("failed to execute request: %w", err)
-	}
-
-	return resp, nil
-}
-
 // WorkflowCmd creates the workflow command
 func WorkflowCmd() *cobra.Command {
 	workflowCmd := &cobra.Command{
@@ -63,18 +35,23 @@ func WorkflowCmd() *cobra.Command {
 				}
 			}
 
-			resp, err := StartWorkflow(workflowName, clientID)
+			client, err := utils.NewClient(cmd.Context())
 			if err != nil {
-				return fmt.Errorf("failed to start workflow: %w", err)
+				return err
 			}
-			defer resp.Body.Close()
-
-			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
-				fmt.Printf("Workflow %s started successfully for client %s\n", workflowName, clientID)
-			} else {
-				fmt.Printf("Failed to start workflow. Status: %s\n", resp.Status)
+			err = utils.Apply(cmd.Context(), cmd.OutOrStdout(), utils.Task{
+				Resource: "workflow",
+				Action:   "start",
+				Name:     fmt.Sprintf("%s for %s", workflowName, clientID),
+				Message:  fmt.Sprintf("Workflow %s started successfully for client %s", workflowName, clientID),
+				Outcome:  utils.OutcomeCreated,
+				Do: func(ctx context.Context) (nanohub.Change, error) {
+					return client.StartWorkflow(ctx, workflowName, clientID)
+				},
+			})
+			if err != nil {
+				return fmt.Errorf("failed to start workflow: %w", err)
 			}
-
 			return nil
 		},
 	}
@@ -6,11 +6,12 @@ import (
 	"fmt"
 	"io"
 	"net/http"
-	"os"
-	"path/filepath"
+	"slices"
 	"time"
 
 	"github.com/spf13/cobra"
+
+	"github.com/macadmins/nanohubctl/internal/utils"
 )
 
 type ProvisioningResponse struct {
@@ -23,55 +24,57 @@ func newCmd() *cobra.Command {
 	newCmd := &cobra.Command{
 		Use:   "new",
 		Short: "Create a new nanohub instance",
-		Long:  "Create a new nanohub instance with the provided token",
+		Long:  "Create a new nanohub instance with the provided token. The instance is saved as a profile for the rest of nanohubctl to use.",
 		RunE:  newCmdFn,
 	}
 
 	newCmd.Flags().StringP("token", "t", "", "Token for authentication")
+	newCmd.Flags().StringP("name", "n", "", "Profile name to save the instance as (default: the instance name)")
+	newCmd.Flags().BoolP("force", "f", false, "Provision another instance even if one is already configured, replacing one saved under the same name")
 	newCmd.MarkFlagRequired("token")
 
 	return newCmd
 }
 
 func newCmdFn(cmd *cobra.Command, args []string) error {
-	// Get home directory and create config path
-	homeDir, err := os.UserHomeDir()
+	instances, err := utils.ReadInstances()
 	if err != nil {
-		return fmt.Errorf("failed to get home directory: %v", err)
-	}
-	
-	configDir := filepath.Join(homeDir, ".nanohubctl")
-	configFile := filepath.Join(configDir, "config.json")
-	
-	// Check if config file already exists
-	if _, err := os.Stat(configFile); err == nil {
-		// Config exists, read and display it
-		data, err := os.ReadFile(configFile)
-		if err != nil {
-			return fmt.Errorf("failed to read existing config: %v", err)
-		}
-		
-		var existingResp ProvisioningResponse
-		if err := json.Unmarshal(data, &existingResp); err != nil {
-			return fmt.Errorf("failed to parse existing config: %v", err)
-		}
-		
+		return err
+	}
+	profileName, err := cmd.Flags().GetString("name")
+	if err != nil {
+		return err
+	}
+	force, err := cmd.Flags().GetBool("force")
+	if err != nil {
+		return err
+	}
+
+	if len(instances) > 0 && !force {
+		// Already provisioned, show what we have instead of creating another
 		fmt.Printf("You already have a nanohub instance configured:\n\n")
-		printInstanceInfo(existingResp)
+		for _, instance := range instances {
+			printInstanceInfo(instance)
+		}
+		fmt.Printf("\nUse --force to provision an additional instance.\n")
 		return nil
 	}
-	
-	// Config doesn't exist, proceed with creating new instance
+	if profileName != "" && slices.ContainsFunc(instances, func(i utils.Instance) bool { return i.ProfileName() == profileName }) {
+		return utils.NewUsageError("an instance named %s already exists", profileName)
+	}
+
 	token, err := cmd.Flags().GetString("token")
 	if err != nil {
 		return err
 	}
 
-	client := &http.Client{
-		Timeout: 60 * time.Second, // Set timeout to 60 seconds since it can take > 30s
+	client, err := utils.HTTPClient()
+	if err != nil {
+		return err
 	}
+	client.Timeout = 60 * time.Second // Set timeout to 60 seconds since it can take > 30s
 
-	req, err := http.NewRequest("POST", "https://provisioning.macadmins.io/new", nil)
+	req, err := http.NewRequestWithContext(cmd.Context(), "POST", "https://provisioning.macadmins.io/new", nil)
 	if err != nil {
 		return err
 	}
@@ -103,32 +106,38 @@ func newCmdFn(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("failed to parse JSON response: %v\nResponse body: %s", err, string(body))
 	}
 
-	// Create config directory if it doesn't exist
-	if err := os.MkdirAll(configDir, 0755); err != nil {
-		return fmt.Errorf("failed to create config directory: %v", err)
+	instance := utils.Instance{
+		Profile:           profileName,
+		Name:              provisioningResp.Name,
+		EnrollmentProfile: provisioningResp.EnrollmentProfile,
+		APIKey:            provisioningResp.APIKey,
 	}
-	
-	// Save the response to config file
-	configData, err := json.MarshalIndent(provisioningResp, "", "  ")
-	if err != nil {
-		return fmt.Errorf("failed to marshal config data: %v", err)
+	// With --force the provisioned instance may already be saved under its
+	// name, replace that entry rather than keep two profiles of one name
+	i := slices.IndexFunc(instances, func(i utils.Instance) bool { return i.ProfileName() == instance.ProfileName() })
+	if i >= 0 {
+		instances[i] = instance
+	} else {
+		instances = append(instances, instance)
 	}
-	
-	if err := os.WriteFile(configFile, configData, 0600); err != nil {
-		return fmt.Errorf("failed to write config file: %v", err)
+	if err := utils.WriteInstances(instances); err != nil {
+		return err
 	}
 
 	// Print formatted output
+	if i >= 0 {
+		fmt.Printf("Replaced the existing instance saved as %s\n", instance.ProfileName())
+	}
 	fmt.Printf("Successfully created new nanohub instance:\n\n")
-	printInstanceInfo(provisioningResp)
+	printInstanceInfo(instance)
 	return nil
 }
 
-func printInstanceInfo(resp ProvisioningResponse) {
-	fmt.Printf("Instance Name:               %s\n", resp.Name)
-	fmt.Printf("API Key:                     %s\n", resp.APIKey)
-	fmt.Printf("Enrollment Profile:          %s\n", resp.EnrollmentProfile)
-	fmt.Printf("\nTo configure nanohubctl, run:\n\n")
-	fmt.Printf("export NANOHUB_URL=https://%s\n", resp.Name)
-	fmt.Printf("export NANOHUB_API_KEY=%s\n", resp.APIKey)
+func printInstanceInfo(instance utils.Instance) {
+	fmt.Printf("Instance Name:               %s\n", instance.Name)
+	fmt.Printf("API Key:                     %s\n", instance.APIKey)
+	fmt.Printf("Enrollment Profile:          %s\n", instance.EnrollmentProfile)
+	fmt.Printf("Profile:                     %s\n", instance.ProfileName())
+	fmt.Printf("\nTo use this instance, run:\n\n")
+	fmt.Printf("nanohubctl config use-profile %s\n\n", instance.ProfileName())
 }
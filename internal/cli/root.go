@@ -2,7 +2,13 @@ package cli
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
+	"os"
+	"slices"
+	"strings"
+	"time"
 
 	"github.com/google/logger"
 	"github.com/spf13/cobra"
@@ -11,6 +17,9 @@ import (
 	"github.com/macadmins/nanohubctl/internal/cli/ddm"
 	"github.com/macadmins/nanohubctl/internal/cli/godeclr"
 	"github.com/macadmins/nanohubctl/internal/cli/nanocmd"
+	"github.com/macadmins/nanohubctl/internal/output"
+	"github.com/macadmins/nanohubctl/internal/utils"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
 )
 
 var (
@@ -18,18 +27,54 @@ var (
 	debug bool
 	vv    bool
 
+	// started is set once cobra has parsed flags and arguments and begins
+	// running the command. Errors before that point are usage errors.
+	started bool
+
+	// cancelTimeout releases the --timeout context once the command is done
+	cancelTimeout context.CancelFunc = func() {}
+
 	version string = "1.0.8"
 )
 
 func setLoggerOpts() {
+	logger.Init("nanohubctl", false, false, os.Stderr)
+	logger.SetFlags(log.LUTC)
 	if vv {
 		logger.SetLevel(2)
 	}
-	logger.SetFlags(log.LUTC)
 }
 
+// ExecuteWithContext runs nanohubctl. Errors are printed to stderr and
+// returned so the caller can turn them into an exit code with utils.ExitCode.
 func ExecuteWithContext(ctx context.Context) error {
-	return rootCmd().ExecuteContext(ctx)
+	cmd, err := rootCmd().ExecuteContextC(ctx)
+	cancelTimeout()
+	if err == nil {
+		return nil
+	}
+	if !started {
+		err = &utils.UsageError{Err: err}
+	}
+	if errors.Is(err, utils.ErrDifferences) {
+		return err
+	}
+	fmt.Fprintln(cmd.ErrOrStderr(), "Error:", err)
+	var usageErr *utils.UsageError
+	if errors.As(err, &usageErr) {
+		fmt.Fprintf(cmd.ErrOrStderr(), "Run '%s --help' for usage.\n", cmd.CommandPath())
+	}
+	return err
+}
+
+// managesConfig reports whether cmd is one of the config or instance commands
+func managesConfig(cmd *cobra.Command) bool {
+	for ; cmd.HasParent(); cmd = cmd.Parent() {
+		if !cmd.Parent().HasParent() {
+			return cmd.Name() == "config" || cmd.Name() == "instance"
+		}
+	}
+	return false
 }
 
 func rootCmd() *cobra.Command {
@@ -37,8 +82,38 @@ func rootCmd() *cobra.Command {
 		Use:   "nanohubctl [ subcommand ]",
 		Short: "A command line tool for working with nanohub",
 		Long:  "A command line tool for working with nanohub APIs",
-		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			started = true
 			setLoggerOpts()
+			if err := utils.LoadConfig(cmd.Flags()); err != nil {
+				// The config and instance commands are how a missing profile
+				// is fixed, so they still run
+				var notFound *utils.ProfileNotFoundError
+				if !errors.As(err, &notFound) || !managesConfig(cmd) {
+					return err
+				}
+				fmt.Fprintln(cmd.ErrOrStderr(), "Warning:", err)
+			}
+			if format := viper.GetString("output"); format != "" && !slices.Contains(output.Formats, format) {
+				return utils.NewUsageError("unknown output format %q, valid formats are: %s", format, strings.Join(output.Formats, ", "))
+			}
+			if viper.GetString("output") != "" && (viper.GetString("template") != "" || viper.GetString("template_file") != "") {
+				return utils.NewUsageError("--output cannot be combined with --template or --template-file")
+			}
+			if _, err := output.Template(); err != nil {
+				return &utils.UsageError{Err: err}
+			}
+			if expr := viper.GetString("jq"); expr != "" {
+				if _, err := output.CompileJQ(expr); err != nil {
+					return &utils.UsageError{Err: err}
+				}
+			}
+			if timeout := viper.GetDuration("timeout"); timeout > 0 {
+				var ctx context.Context
+				ctx, cancelTimeout = context.WithTimeout(cmd.Context(), timeout)
+				cmd.SetContext(ctx)
+			}
+			return nil
 		},
 		RunE: func(cmd *cobra.Command, args []string) error {
 			if err := cmd.Help(); err != nil {
@@ -46,16 +121,35 @@ func rootCmd() *cobra.Command {
 			}
 			return nil
 		},
-		Args:    cobra.NoArgs,
-		Version: version,
+		Args:          cobra.NoArgs,
+		Version:       version,
+		SilenceErrors: true,
+		SilenceUsage:  true,
 	}
 
 	// At the rootCmd level, set these global flags that will be available to downstream cmds
+	rootCmd.PersistentFlags().String("config", "", "Config file (default ~/.nanohubctl/config.yaml)")
+	rootCmd.PersistentFlags().StringP("profile", "p", "", "Named profile from the config file to use")
 	rootCmd.PersistentFlags().String("url", "", "URL of the ddm instance")
 	rootCmd.PersistentFlags().String("api_key", "", "API key for the ddm instance")
+	rootCmd.PersistentFlags().String("api_key_file", "", "File to read the API key from, - for stdin")
+	rootCmd.PersistentFlags().String("api_key_command", "", "Command whose output is the API key, e.g. a password manager CLI")
 	rootCmd.PersistentFlags().String("api_user", "nanohub", "API key for the ddm instance")
 	rootCmd.PersistentFlags().String("client_id", "", "Client ID to apply items to")
-	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Run in debug mode")
+	rootCmd.PersistentFlags().Int("retries", 3, "Number of times to retry idempotent requests after a transient failure")
+	rootCmd.PersistentFlags().Duration("retry_max_wait", 30*time.Second, "Maximum backoff between retries")
+	rootCmd.PersistentFlags().Int("concurrency", 4, "Number of requests bulk operations run in parallel")
+	rootCmd.PersistentFlags().Duration("timeout", 0, "Abort the command after this long, e.g. 30s or 5m (0 waits forever)")
+	rootCmd.PersistentFlags().String("ca_bundle", "", "PEM file of additional CA certificates to trust")
+	rootCmd.PersistentFlags().String("client_cert", "", "PEM client certificate for mutual TLS")
+	rootCmd.PersistentFlags().String("client_key", "", "PEM private key for --client_cert")
+	rootCmd.PersistentFlags().String("https_proxy", "", "Proxy URL to use instead of the HTTPS_PROXY environment variable")
+	rootCmd.PersistentFlags().Bool("insecure_skip_verify", false, "Skip TLS certificate verification (INSECURE, testing only)")
+	rootCmd.PersistentFlags().StringP("output", "o", "", "Output format for results: json, yaml, table, csv or name")
+	rootCmd.PersistentFlags().String("jq", "", "jq expression to filter the JSON result with before it is printed")
+	rootCmd.PersistentFlags().String("template", "", "Go template to render the result with, see the README for helper functions")
+	rootCmd.PersistentFlags().String("template_file", "", "File containing a Go template to render the result with")
+	rootCmd.PersistentFlags().BoolVar(&debug, "debug", false, "Dump HTTP requests and responses to stderr, with credentials redacted")
 	rootCmd.PersistentFlags().BoolVar(&vv, "vv", false, "Run in verbose logging mode")
 	if vv {
 		logger.SetLevel(2)
@@ -65,24 +159,62 @@ func rootCmd() *cobra.Command {
 	// 1. PFlags
 	// 2. ENV vars
 	// 3. If both Flag and Env var are set, flag wins
-	// 4. Defaults
+	// 4. The active profile in the config file
+	// 5. Global settings in the config file
+	// 6. Defaults
 
 	// Bind PFlags to viper settings
+	viper.BindPFlag("config", rootCmd.PersistentFlags().Lookup("config"))
+	viper.BindPFlag("profile", rootCmd.PersistentFlags().Lookup("profile"))
 	viper.BindPFlag("url", rootCmd.PersistentFlags().Lookup("url"))
 	viper.BindPFlag("api_key", rootCmd.PersistentFlags().Lookup("api_key"))
+	viper.BindPFlag("api_key_file", rootCmd.PersistentFlags().Lookup("api_key_file"))
+	viper.BindPFlag("api_key_command", rootCmd.PersistentFlags().Lookup("api_key_command"))
 	viper.BindPFlag("api_user", rootCmd.PersistentFlags().Lookup("api_user"))
 	viper.BindPFlag("client_id", rootCmd.PersistentFlags().Lookup("client_id"))
+	viper.BindPFlag("retries", rootCmd.PersistentFlags().Lookup("retries"))
+	viper.BindPFlag("retry_max_wait", rootCmd.PersistentFlags().Lookup("retry_max_wait"))
+	viper.BindPFlag("concurrency", rootCmd.PersistentFlags().Lookup("concurrency"))
+	viper.BindPFlag("timeout", rootCmd.PersistentFlags().Lookup("timeout"))
+	viper.BindPFlag("ca_bundle", rootCmd.PersistentFlags().Lookup("ca_bundle"))
+	viper.BindPFlag("client_cert", rootCmd.PersistentFlags().Lookup("client_cert"))
+	viper.BindPFlag("client_key", rootCmd.PersistentFlags().Lookup("client_key"))
+	viper.BindPFlag("https_proxy", rootCmd.PersistentFlags().Lookup("https_proxy"))
+	viper.BindPFlag("insecure_skip_verify", rootCmd.PersistentFlags().Lookup("insecure_skip_verify"))
+	viper.BindPFlag("output", rootCmd.PersistentFlags().Lookup("output"))
+	viper.BindPFlag("jq", rootCmd.PersistentFlags().Lookup("jq"))
+	viper.BindPFlag("template", rootCmd.PersistentFlags().Lookup("template"))
+	viper.BindPFlag("template_file", rootCmd.PersistentFlags().Lookup("template_file"))
+	viper.BindPFlag("debug", rootCmd.PersistentFlags().Lookup("debug"))
 
 	// Set up ENV namespace and ENV vars
 	// All env vars will be prefixed with DDM
 	viper.SetEnvPrefix("NANOHUB")
+	viper.BindEnv("CONFIG")
+	viper.BindEnv("PROFILE")
 	viper.BindEnv("URL")
 	viper.BindEnv("API_KEY")
+	viper.BindEnv("API_KEY_FILE")
+	viper.BindEnv("API_KEY_COMMAND")
 	viper.BindEnv("API_USER")
 	viper.BindEnv("CLIENT_ID")
+	viper.BindEnv("RETRIES")
+	viper.BindEnv("RETRY_MAX_WAIT")
+	viper.BindEnv("CONCURRENCY")
+	viper.BindEnv("TIMEOUT")
+	viper.BindEnv("CA_BUNDLE")
+	viper.BindEnv("CLIENT_CERT")
+	viper.BindEnv("CLIENT_KEY")
+	viper.BindEnv("HTTPS_PROXY")
+	viper.BindEnv("INSECURE_SKIP_VERIFY")
+	viper.BindEnv("OUTPUT")
+	viper.BindEnv("DEBUG")
 
 	// Set defaults
 	viper.SetDefault("api_user", "nanohub")
+	viper.SetDefault("concurrency", 4)
+	viper.SetDefault("retries", nanohub.DefaultRetryPolicy.MaxRetries)
+	viper.SetDefault("retry_max_wait", nanohub.DefaultRetryPolicy.MaxWait)
 
 	// Import subCmds into the rootCmd
 	rootCmd.AddCommand(
@@ -90,6 +222,8 @@ func rootCmd() *cobra.Command {
 		nanocmd.RootCmd(),
 		godeclr.RootCmd(),
 		newCmd(),
+		configCmd(),
+		instanceCmd(),
 	)
 
 	return rootCmd
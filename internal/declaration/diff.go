@@ -0,0 +1,240 @@
+package declaration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+)
+
+// serverFields are added by KMFDDM and never part of a declaration file
+var serverFields = []string{"ServerToken"}
+
+// Normalize decodes a JSON encoded declaration and drops the fields the
+// server adds, so a file and the server's copy of it compare equal
+func Normalize(declJSON []byte) (map[string]any, error) {
+	dec := json.NewDecoder(bytes.NewReader(declJSON))
+	dec.UseNumber()
+	var decl map[string]any
+	if err := dec.Decode(&decl); err != nil {
+		return nil, err
+	}
+	for _, field := range serverFields {
+		delete(decl, field)
+	}
+	return decl, nil
+}
+
+// Indent encodes a normalized declaration as indented JSON with sorted keys
+func Indent(decl map[string]any) string {
+	if decl == nil {
+		return ""
+	}
+	b, _ := json.MarshalIndent(decl, "", "  ")
+	return string(b) + "\n"
+}
+
+// Change is one difference between two declarations
+type Change struct {
+	// Path of the field, e.g. Payload.MinimumLength or
+	// Payload.StandardConfigurations[1]
+	Path string `json:"path"`
+	// Op is + for a field only in the new declaration, - for one only in the
+	// old and ~ for one with a different value
+	Op  string `json:"op"`
+	Old any    `json:"old,omitempty"`
+	New any    `json:"new,omitempty"`
+}
+
+func (c Change) String() string {
+	switch c.Op {
+	case "+":
+		return fmt.Sprintf("+ %s: %s", c.Path, compact(c.New))
+	case "-":
+		return fmt.Sprintf("- %s: %s", c.Path, compact(c.Old))
+	}
+	return fmt.Sprintf("~ %s: %s -> %s", c.Path, compact(c.Old), compact(c.New))
+}
+
+func compact(v any) string {
+	b, _ := json.Marshal(v)
+	return string(b)
+}
+
+// Compare returns the field by field differences from old to new
+func Compare(old, new map[string]any) []Change {
+	var changes []Change
+	compare("", old, new, &changes)
+	return changes
+}
+
+func compare(path string, old, new any, changes *[]Change) {
+	switch o := old.(type) {
+	case map[string]any:
+		n, ok := new.(map[string]any)
+		if !ok {
+			break
+		}
+		keys := sortedKeys(o)
+		for _, k := range sortedKeys(n) {
+			if _, ok := o[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		slices.Sort(keys)
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			ov, inOld := o[k]
+			nv, inNew := n[k]
+			switch {
+			case !inOld:
+				*changes = append(*changes, Change{Path: p, Op: "+", New: nv})
+			case !inNew:
+				*changes = append(*changes, Change{Path: p, Op: "-", Old: ov})
+			default:
+				compare(p, ov, nv, changes)
+			}
+		}
+		return
+	case []any:
+		n, ok := new.([]any)
+		if !ok {
+			break
+		}
+		for i := range max(len(o), len(n)) {
+			p := fmt.Sprintf("%s[%d]", path, i)
+			switch {
+			case i >= len(o):
+				*changes = append(*changes, Change{Path: p, Op: "+", New: n[i]})
+			case i >= len(n):
+				*changes = append(*changes, Change{Path: p, Op: "-", Old: o[i]})
+			default:
+				compare(p, o[i], n[i], changes)
+			}
+		}
+		return
+	}
+	if !reflect.DeepEqual(old, new) {
+		*changes = append(*changes, Change{Path: path, Op: "~", Old: old, New: new})
+	}
+}
+
+// diffContext is the number of unchanged lines shown around changes
+const diffContext = 3
+
+// UnifiedDiff returns the differences between the texts a and b in unified
+// diff format, empty if they are equal
+func UnifiedDiff(aName, bName, a, b string) string {
+	if a == b {
+		return ""
+	}
+	aLines, bLines := splitLines(a), splitLines(b)
+	ops := diffLines(aLines, bLines)
+
+	var out strings.Builder
+	fmt.Fprintf(&out, "--- %s\n+++ %s\n", aName, bName)
+	for start := 0; start < len(ops); {
+		// Find the next change and the extent of its hunk
+		for start < len(ops) && ops[start].kind == ' ' {
+			start++
+		}
+		if start == len(ops) {
+			break
+		}
+		first := max(start-diffContext, 0)
+		end := start
+		for end < len(ops) {
+			if ops[end].kind != ' ' {
+				end++
+				continue
+			}
+			// Close the hunk after enough unchanged lines
+			run := end
+			for run < len(ops) && ops[run].kind == ' ' {
+				run++
+			}
+			if run == len(ops) || run-end > 2*diffContext {
+				end = min(end+diffContext, len(ops))
+				break
+			}
+			end = run
+		}
+
+		aStart, bStart := ops[first].a, ops[first].b
+		aCount, bCount := 0, 0
+		for _, op := range ops[first:end] {
+			if op.kind != '+' {
+				aCount++
+			}
+			if op.kind != '-' {
+				bCount++
+			}
+		}
+		fmt.Fprintf(&out, "@@ -%s +%s @@\n", hunkRange(aStart, aCount), hunkRange(bStart, bCount))
+		for _, op := range ops[first:end] {
+			fmt.Fprintf(&out, "%c%s\n", op.kind, op.line)
+		}
+		start = end
+	}
+	return out.String()
+}
+
+func hunkRange(start, count int) string {
+	if count == 0 {
+		return fmt.Sprintf("%d,0", start)
+	}
+	return fmt.Sprintf("%d,%d", start+1, count)
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
+
+type lineOp struct {
+	kind byte // ' ', '-' or '+'
+	line string
+	// a and b are the 0 based line numbers in each text before this line
+	a, b int
+}
+
+// diffLines computes a line diff from the longest common subsequence
+func diffLines(a, b []string) []lineOp {
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+	var ops []lineOp
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			ops = append(ops, lineOp{' ', a[i], i, j})
+			i++
+			j++
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			ops = append(ops, lineOp{'-', a[i], i, j})
+			i++
+		default:
+			ops = append(ops, lineOp{'+', b[j], i, j})
+			j++
+		}
+	}
+	return ops
+}
@@ -0,0 +1,190 @@
+package declaration
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// numbered returns the lines 1 to n, with the lines in changed replaced
+func numbered(n int, changed map[int]string) string {
+	var b strings.Builder
+	for i := 1; i <= n; i++ {
+		if line, ok := changed[i]; ok {
+			b.WriteString(line + "\n")
+			continue
+		}
+		fmt.Fprintf(&b, "%d\n", i)
+	}
+	return b.String()
+}
+
+func TestUnifiedDiff(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "identical",
+			a:    numbered(5, nil),
+			b:    numbered(5, nil),
+		},
+		{
+			name: "change at the start",
+			a:    numbered(8, nil),
+			b:    numbered(8, map[int]string{1: "x"}),
+			want: "@@ -1,4 +1,4 @@\n-1\n+x\n 2\n 3\n 4\n",
+		},
+		{
+			name: "change at the end",
+			a:    numbered(8, nil),
+			b:    numbered(8, map[int]string{8: "x"}),
+			want: "@@ -5,4 +5,4 @@\n 5\n 6\n 7\n-8\n+x\n",
+		},
+		{
+			name: "empty old side",
+			a:    "",
+			b:    "a\nb\n",
+			want: "@@ -0,0 +1,2 @@\n+a\n+b\n",
+		},
+		{
+			name: "empty new side",
+			a:    "a\nb\n",
+			b:    "",
+			want: "@@ -1,2 +0,0 @@\n-a\n-b\n",
+		},
+		{
+			name: "insertion at the start",
+			a:    "a\n",
+			b:    "x\na\n",
+			want: "@@ -1,1 +1,2 @@\n+x\n a\n",
+		},
+		{
+			name: "changes far apart",
+			a:    numbered(13, nil),
+			b:    numbered(13, map[int]string{2: "x", 10: "y"}),
+			want: "@@ -1,5 +1,5 @@\n 1\n-2\n+x\n 3\n 4\n 5\n" +
+				"@@ -7,7 +7,7 @@\n 7\n 8\n 9\n-10\n+y\n 11\n 12\n 13\n",
+		},
+		{
+			name: "changes close together",
+			a:    numbered(12, nil),
+			b:    numbered(12, map[int]string{2: "x", 9: "y"}),
+			want: "@@ -1,12 +1,12 @@\n 1\n-2\n+x\n 3\n 4\n 5\n 6\n 7\n 8\n-9\n+y\n 10\n 11\n 12\n",
+		},
+		{
+			name: "adjacent changes",
+			a:    numbered(4, nil),
+			b:    numbered(4, map[int]string{2: "x", 3: "y"}),
+			want: "@@ -1,4 +1,4 @@\n 1\n-2\n-3\n+x\n+y\n 4\n",
+		},
+		{
+			name: "no trailing newline",
+			a:    "a",
+			b:    "b",
+			want: "@@ -1,1 +1,1 @@\n-a\n+b\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			want := tt.want
+			if want != "" {
+				want = "--- old\n+++ new\n" + want
+			}
+			if got := UnifiedDiff("old", "new", tt.a, tt.b); got != want {
+				t.Errorf("UnifiedDiff() =\n%s\nwant\n%s", got, want)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	decode := func(s string) map[string]any {
+		decl, err := Normalize([]byte(s))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return decl
+	}
+	server := decode(`{
+		"Type": "com.apple.activation.simple",
+		"Identifier": "act",
+		"ServerToken": "abc",
+		"Payload": {"StandardConfigurations": ["a", "b"], "Predicate": "x"}
+	}`)
+
+	tests := []struct {
+		name string
+		old  map[string]any
+		new  string
+		want []string
+	}{
+		{
+			name: "unchanged, ServerToken ignored",
+			old:  server,
+			new:  `{"Identifier": "act", "Type": "com.apple.activation.simple", "Payload": {"Predicate": "x", "StandardConfigurations": ["a", "b"]}}`,
+		},
+		{
+			name: "new",
+			new:  `{"Identifier": "act", "Type": "t", "Payload": {}}`,
+			want: []string{`+ Identifier: "act"`, `+ Payload: {}`, `+ Type: "t"`},
+		},
+		{
+			name: "changed",
+			old:  server,
+			new:  `{"Identifier": "act", "Type": "com.apple.activation.simple", "Payload": {"StandardConfigurations": ["a", "c", "d"], "Extra": 1}}`,
+			want: []string{
+				`+ Payload.Extra: 1`,
+				`- Payload.Predicate: "x"`,
+				`~ Payload.StandardConfigurations[1]: "b" -> "c"`,
+				`+ Payload.StandardConfigurations[2]: "d"`,
+			},
+		},
+		{
+			name: "removed array items",
+			old:  server,
+			new:  `{"Identifier": "act", "Type": "com.apple.activation.simple", "Payload": {"StandardConfigurations": ["a"], "Predicate": "x"}}`,
+			want: []string{`- Payload.StandardConfigurations[1]: "b"`},
+		},
+		{
+			name: "changed kind",
+			old:  server,
+			new:  `{"Identifier": "act", "Type": "com.apple.activation.simple", "Payload": "none"}`,
+			want: []string{`~ Payload: {"Predicate":"x","StandardConfigurations":["a","b"]} -> "none"`},
+		},
+		{
+			name: "changed number",
+			old:  decode(`{"Payload": {"MinimumLength": 6}}`),
+			new:  `{"Payload": {"MinimumLength": 8}}`,
+			want: []string{`~ Payload.MinimumLength: 6 -> 8`},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, change := range Compare(tt.old, decode(tt.new)) {
+				got = append(got, change.String())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Compare() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	decl, err := Normalize([]byte(`{"Identifier": "a", "ServerToken": "abc", "Payload": {"MinimumLength": 6}}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]any{"Identifier": "a", "Payload": map[string]any{"MinimumLength": json.Number("6")}}
+	if !reflect.DeepEqual(decl, want) {
+		t.Errorf("Normalize() = %v, want %v", decl, want)
+	}
+	if got := Indent(nil); got != "" {
+		t.Errorf("Indent(nil) = %q, want empty", got)
+	}
+}
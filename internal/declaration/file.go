@@ -0,0 +1,68 @@
+package declaration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+)
+
+// File is a declaration file read from disk
+type File struct {
+	Path string
+	// JSON is the declaration as uploaded to KMFDDM
+	JSON []byte
+}
+
+// ReadOptions control how declaration files are read
+type ReadOptions struct {
+	// Values are the variables declaration files are rendered with, see Render
+	Values map[string]any
+	// SkipValidation skips validating declarations against their schema
+	SkipValidation bool
+}
+
+// ReadFile reads the declaration file at path and renders it with values.
+// YAML and property list files, told apart by their extension, are converted
+// to JSON. Any other file is taken to be JSON.
+func ReadFile(path string, values map[string]any) (File, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return File{}, err
+	}
+	if b, err = Render(path, b, values); err != nil {
+		return File{}, err
+	}
+	format := FormatOf(path)
+	if format == "" {
+		format = JSON
+	}
+	declJSON, err := ToJSON(format, b)
+	if err != nil {
+		return File{}, fmt.Errorf("%s: invalid %s: %w", path, format, err)
+	}
+	return File{Path: path, JSON: declJSON}, nil
+}
+
+// ReadFiles reads declaration files and, unless opts.SkipValidation is set,
+// validates them all. Validation problems across every file are reported
+// together.
+func ReadFiles(paths []string, opts ReadOptions) ([]File, error) {
+	var files []File
+	var errs []error
+	for _, path := range paths {
+		f, err := ReadFile(path, opts.Values)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, f)
+		if !opts.SkipValidation {
+			if err := Validate(path, f.JSON); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("declarations failed validation, nothing was uploaded (--skip-validation uploads them anyway):\n%w", errors.Join(errs...))
+	}
+	return files, nil
+}
@@ -0,0 +1,177 @@
+package declaration
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"howett.net/plist"
+)
+
+// Declaration file formats
+const (
+	JSON  = "json"
+	YAML  = "yaml"
+	Plist = "plist"
+)
+
+// Formats lists the declaration file formats
+var Formats = []string{JSON, YAML, Plist}
+
+// extensions maps file extensions to declaration formats
+var extensions = map[string]string{
+	".json":  JSON,
+	".yaml":  YAML,
+	".yml":   YAML,
+	".plist": Plist,
+}
+
+// FormatOf returns the declaration format of path from its extension, empty
+// if it is not a declaration file
+func FormatOf(path string) string {
+	return extensions[strings.ToLower(filepath.Ext(path))]
+}
+
+// IsFile reports whether path has the extension of a declaration file
+func IsFile(path string) bool {
+	return FormatOf(path) != ""
+}
+
+// ToJSON converts a declaration in format to the JSON KMFDDM expects. JSON is
+// returned unchanged.
+func ToJSON(format string, b []byte) ([]byte, error) {
+	var v any
+	switch format {
+	case JSON:
+		return b, nil
+	case YAML:
+		if err := yaml.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+	case Plist:
+		if _, err := plist.Unmarshal(b, &v); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown declaration format %q", format)
+	}
+	v, err := jsonValue(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// jsonValue converts decoded YAML or plist values to ones encoding/json can
+// marshal, YAML mappings may have non-string keys
+func jsonValue(v any) (any, error) {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[k] = item
+		}
+		return v, nil
+	case map[any]any:
+		m := make(map[string]any, len(v))
+		for k, item := range v {
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			m[fmt.Sprint(k)] = item
+		}
+		return m, nil
+	case []any:
+		for i, item := range v {
+			item, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = item
+		}
+		return v, nil
+	case time.Time:
+		return v.UTC().Format(time.RFC3339), nil
+	}
+	return v, nil
+}
+
+// FromJSON converts a JSON encoded declaration to format. YAML keeps the order
+// of the JSON keys.
+func FromJSON(format string, b []byte) ([]byte, error) {
+	switch format {
+	case JSON:
+		return b, nil
+	case YAML:
+		// JSON is YAML, decoding it into a node keeps the key order
+		var node yaml.Node
+		if err := yaml.Unmarshal(b, &node); err != nil {
+			return nil, err
+		}
+		blockStyle(&node)
+		var buf bytes.Buffer
+		enc := yaml.NewEncoder(&buf)
+		enc.SetIndent(2)
+		if err := enc.Encode(&node); err != nil {
+			return nil, err
+		}
+		if err := enc.Close(); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	case Plist:
+		dec := json.NewDecoder(bytes.NewReader(b))
+		dec.UseNumber()
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		return plist.MarshalIndent(plistValue(v), plist.XMLFormat, "\t")
+	}
+	return nil, fmt.Errorf("unknown declaration format %q, valid formats are: %s", format, strings.Join(Formats, ", "))
+}
+
+// blockStyle drops the flow style and quoting JSON input leaves on nodes.
+// Strings that would read as another type stay quoted.
+func blockStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		blockStyle(child)
+	}
+}
+
+// plistValue turns JSON numbers into plist integers and reals
+func plistValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, item := range v {
+			v[k] = plistValue(item)
+		}
+	case []any:
+		for i, item := range v {
+			v[i] = plistValue(item)
+		}
+	case json.Number:
+		if n, err := v.Int64(); err == nil {
+			return n
+		}
+		if f, err := v.Float64(); err == nil {
+			return f
+		}
+	}
+	return v
+}
+
+// ValidFormat reports whether format is one of Formats
+func ValidFormat(format string) bool {
+	return slices.Contains(Formats, format)
+}
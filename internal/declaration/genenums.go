@@ -0,0 +1,107 @@
+//go:build ignore
+
+// genenums writes enums_gen.go: the allowed values of every enum type in the
+// go-adm declarations packages, which reflection cannot see.
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/format"
+	"go/parser"
+	"go/token"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const module = "github.com/korylprince/go-adm"
+
+var packages = []string{"activations", "assets", "configurations", "management"}
+
+func main() {
+	out, err := exec.Command("go", "list", "-m", "-f", "{{.Dir}}", module).Output()
+	if err != nil {
+		log.Fatalf("locating %s: %v", module, err)
+	}
+	dir := strings.TrimSpace(string(out))
+
+	enums := map[string][]string{}
+	for _, pkg := range packages {
+		importPath := module + "/declarations/" + pkg
+		fset := token.NewFileSet()
+		files, err := filepath.Glob(filepath.Join(dir, "declarations", pkg, "*.go"))
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, path := range files {
+			if strings.HasSuffix(path, "_test.go") {
+				continue
+			}
+			f, err := parser.ParseFile(fset, path, nil, 0)
+			if err != nil {
+				log.Fatal(err)
+			}
+			for _, decl := range f.Decls {
+				gen, ok := decl.(*ast.GenDecl)
+				if !ok || gen.Tok != token.CONST {
+					continue
+				}
+				for _, spec := range gen.Specs {
+					vs := spec.(*ast.ValueSpec)
+					typ, ok := vs.Type.(*ast.Ident)
+					if !ok {
+						continue
+					}
+					for _, v := range vs.Values {
+						lit, ok := v.(*ast.BasicLit)
+						if !ok || lit.Kind != token.STRING {
+							continue
+						}
+						s, err := strconv.Unquote(lit.Value)
+						if err != nil {
+							log.Fatal(err)
+						}
+						key := importPath + "." + typ.Name
+						enums[key] = append(enums[key], s)
+					}
+				}
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(enums))
+	for k := range enums {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by genenums.go; DO NOT EDIT.\n\npackage declaration\n\n")
+	fmt.Fprintf(&buf, "// enums maps go-adm enum types, by package path and name, to their values\n")
+	fmt.Fprintf(&buf, "var enums = map[string][]string{\n")
+	for _, k := range keys {
+		fmt.Fprintf(&buf, "\t%q: {", k)
+		for i, v := range enums[k] {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+			fmt.Fprintf(&buf, "%q", v)
+		}
+		buf.WriteString("},\n")
+	}
+	buf.WriteString("}\n")
+
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile("enums_gen.go", src, 0644); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,254 @@
+package declaration
+
+import (
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/korylprince/go-adm/declarations"
+)
+
+// Declaration classes, the third element of a declaration Type
+const (
+	ClassAsset         = "asset"
+	ClassConfiguration = "configuration"
+	ClassManagement    = "management"
+	ClassActivation    = "activation"
+)
+
+// Class returns the class of a declaration type, e.g. configuration for
+// com.apple.configuration.passcode.settings, or empty if it has none
+func Class(typ string) string {
+	parts := strings.Split(typ, ".")
+	if len(parts) < 3 {
+		return ""
+	}
+	return parts[2]
+}
+
+// Reference is a field of one declaration holding the identifier of another
+type Reference struct {
+	From string `json:"from"`
+	// Field is the path of the referencing field, e.g.
+	// Payload.StandardConfigurations[0]
+	Field string `json:"field"`
+	To    string `json:"to"`
+	// Class is the class the referenced declaration must have
+	Class string `json:"class"`
+}
+
+// References returns the references in decl. They are found with the go-adm
+// schema of its Type: fields named ...AssetReference(s) refer to assets and
+// an activation's StandardConfigurations to configurations.
+func References(decl map[string]any) []Reference {
+	typ, _ := decl["Type"].(string)
+	schema, ok := declarations.DeclarationMap[typ]
+	if !ok {
+		return nil
+	}
+	from, _ := decl["Identifier"].(string)
+	var refs []Reference
+	collectRefs(from, "Payload", reflect.TypeOf(schema), decl["Payload"], "", &refs)
+	return refs
+}
+
+// collectRefs walks value along the Go type t. class is the class the field
+// refers to if it is a reference field.
+func collectRefs(from, field string, t reflect.Type, value any, class string, refs *[]Reference) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.String:
+		if s, ok := value.(string); ok && class != "" && s != "" {
+			*refs = append(*refs, Reference{From: from, Field: field, To: s, Class: class})
+		}
+	case reflect.Slice, reflect.Array:
+		items, _ := value.([]any)
+		for i, item := range items {
+			collectRefs(from, fmt.Sprintf("%s[%d]", field, i), t.Elem(), item, class, refs)
+		}
+	case reflect.Struct:
+		obj, _ := value.(map[string]any)
+		for i := range t.NumField() {
+			f := t.Field(i)
+			name := jsonName(f)
+			v, ok := obj[name]
+			if name == "" || !ok {
+				continue
+			}
+			collectRefs(from, field+"."+name, f.Type, v, refClass(t, name), refs)
+		}
+	}
+}
+
+// refClass returns the class the field name of struct t refers to, empty if
+// it is not a reference
+func refClass(t reflect.Type, name string) string {
+	switch {
+	case strings.HasSuffix(name, "AssetReference"), strings.HasSuffix(name, "AssetReferences"):
+		return ClassAsset
+	case name == "StandardConfigurations" && strings.HasSuffix(t.PkgPath(), "/activations"):
+		return ClassConfiguration
+	}
+	return ""
+}
+
+// Graph is the reference graph of a collection of declarations and sets
+type Graph struct {
+	// Declarations are keyed by identifier
+	Declarations map[string]map[string]any
+	// Sets maps set names to the identifiers of their declarations
+	Sets map[string][]string
+	// References are keyed by the identifier they are from
+	References map[string][]Reference
+}
+
+// NewGraph builds the reference graph of decls, keyed by identifier, and
+// sets
+func NewGraph(decls map[string]map[string]any, sets map[string][]string) *Graph {
+	g := &Graph{Declarations: decls, Sets: sets, References: map[string][]Reference{}}
+	for id, decl := range decls {
+		g.References[id] = References(decl)
+	}
+	return g
+}
+
+// Identifiers returns the identifiers of the declarations in the graph, sorted
+func (g *Graph) Identifiers() []string {
+	ids := make([]string, 0, len(g.Declarations))
+	for id := range g.Declarations {
+		ids = append(ids, id)
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// Type returns the Type of the declaration id, empty if it is not in the graph
+func (g *Graph) Type(id string) string {
+	typ, _ := g.Declarations[id]["Type"].(string)
+	return typ
+}
+
+// SetsOf returns the sets id is in, sorted
+func (g *Graph) SetsOf(id string) []string {
+	var sets []string
+	for set, ids := range g.Sets {
+		if slices.Contains(ids, id) {
+			sets = append(sets, set)
+		}
+	}
+	slices.Sort(sets)
+	return sets
+}
+
+// Reachable returns id and every declaration it refers to, directly or
+// indirectly, in the order they are first reached. Missing declarations are
+// included.
+func (g *Graph) Reachable(id string) []string {
+	seen := map[string]bool{}
+	var order []string
+	var visit func(string)
+	visit = func(id string) {
+		if seen[id] {
+			return
+		}
+		seen[id] = true
+		order = append(order, id)
+		for _, ref := range g.References[id] {
+			visit(ref.To)
+		}
+	}
+	visit(id)
+	return order
+}
+
+// Problem is a broken reference
+type Problem struct {
+	Reference
+	Message string `json:"message"`
+}
+
+func (p Problem) String() string {
+	return fmt.Sprintf("%s: %s %s", p.From, p.Field, p.Message)
+}
+
+// Problems returns the references of the declarations in ids that point to
+// missing declarations, to declarations of the wrong class, or to
+// declarations that are not in a set the referring declaration is in. Devices
+// only receive the declarations of their sets, so those references would not
+// resolve.
+func (g *Graph) Problems(ids []string) []Problem {
+	var problems []Problem
+	for _, id := range ids {
+		for _, ref := range g.References[id] {
+			target, ok := g.Declarations[ref.To]
+			if !ok {
+				problems = append(problems, Problem{ref, fmt.Sprintf("refers to %s, which does not exist", ref.To)})
+				continue
+			}
+			if typ, _ := target["Type"].(string); Class(typ) != ref.Class {
+				problems = append(problems, Problem{ref, fmt.Sprintf("refers to %s of type %s, which is not in the %s class", ref.To, typ, ref.Class)})
+			}
+			for _, set := range g.SetsOf(id) {
+				if !slices.Contains(g.Sets[set], ref.To) {
+					problems = append(problems, Problem{ref, fmt.Sprintf("refers to %s, which is not in set %s", ref.To, set)})
+				}
+			}
+		}
+	}
+	return problems
+}
+
+// classOrder is the order declarations are applied in, a class only refers
+// to classes before it
+var classOrder = []string{ClassAsset, ClassConfiguration, ClassManagement, ClassActivation}
+
+// Stages groups ids so that each declaration comes after the declarations it
+// refers to: assets first, then configurations, management and activations,
+// and within a class by references. Declarations of unknown class, such as
+// missing ones, come first as they refer to nothing known and whatever refers
+// to them can only be applied after them. Reverse the stages to remove
+// declarations.
+func (g *Graph) Stages(ids []string) [][]string {
+	depths := map[string]int{}
+	var depth func(id string, path []string) int
+	depth = func(id string, path []string) int {
+		if d, ok := depths[id]; ok {
+			return d
+		}
+		d := 0
+		for _, ref := range g.References[id] {
+			// Cycles are broken where they are found
+			if _, ok := g.Declarations[ref.To]; ok && !slices.Contains(path, ref.To) {
+				d = max(d, depth(ref.To, append(path, ref.To))+1)
+			}
+		}
+		depths[id] = d
+		return d
+	}
+
+	type key struct{ class, depth int }
+	byKey := map[key][]string{}
+	for _, id := range ids {
+		k := key{slices.Index(classOrder, Class(g.Type(id))), depth(id, []string{id})}
+		byKey[k] = append(byKey[k], id)
+	}
+	keys := make([]key, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b key) int {
+		if a.class != b.class {
+			return a.class - b.class
+		}
+		return a.depth - b.depth
+	})
+	stages := make([][]string, len(keys))
+	for i, k := range keys {
+		stages[i] = byKey[k]
+		slices.Sort(stages[i])
+	}
+	return stages
+}
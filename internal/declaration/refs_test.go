@@ -0,0 +1,204 @@
+package declaration
+
+import (
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func decl(typ, id string, payload map[string]any) map[string]any {
+	return map[string]any{"Type": typ, "Identifier": id, "Payload": payload}
+}
+
+const (
+	typeActivation = "com.apple.activation.simple"
+	typePasscode   = "com.apple.configuration.passcode.settings"
+	typeMail       = "com.apple.configuration.account.mail"
+	typeCredential = "com.apple.asset.credential.userpassword"
+)
+
+func activation(id string, configs ...string) map[string]any {
+	var refs []any
+	for _, config := range configs {
+		refs = append(refs, config)
+	}
+	return decl(typeActivation, id, map[string]any{"StandardConfigurations": refs})
+}
+
+func mail(id, credential string) map[string]any {
+	return decl(typeMail, id, map[string]any{
+		"UserIdentityAssetReference": credential,
+		"IncomingServer": map[string]any{
+			"ServerType": "IMAP",
+			"HostName":   "mail.example.com",
+			"AuthenticationCredentialsAssetReference": credential,
+		},
+	})
+}
+
+func graphOf(decls ...map[string]any) *Graph {
+	byID := map[string]map[string]any{}
+	for _, d := range decls {
+		byID[d["Identifier"].(string)] = d
+	}
+	return NewGraph(byID, nil)
+}
+
+func TestReferences(t *testing.T) {
+	tests := []struct {
+		name string
+		decl map[string]any
+		want []Reference
+	}{
+		{
+			name: "activation",
+			decl: activation("act", "a", "b"),
+			want: []Reference{
+				{From: "act", Field: "Payload.StandardConfigurations[0]", To: "a", Class: ClassConfiguration},
+				{From: "act", Field: "Payload.StandardConfigurations[1]", To: "b", Class: ClassConfiguration},
+			},
+		},
+		{
+			name: "nested asset references",
+			decl: mail("mail", "cred"),
+			want: []Reference{
+				{From: "mail", Field: "Payload.IncomingServer.AuthenticationCredentialsAssetReference", To: "cred", Class: ClassAsset},
+				{From: "mail", Field: "Payload.UserIdentityAssetReference", To: "cred", Class: ClassAsset},
+			},
+		},
+		{
+			name: "no references",
+			decl: decl(typePasscode, "pass", map[string]any{"MinimumLength": 6}),
+		},
+		{
+			name: "unknown type",
+			decl: decl("com.example.custom", "custom", map[string]any{"StandardConfigurations": []any{"a"}}),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := References(tt.decl)
+			slices.SortFunc(got, func(a, b Reference) int { return strings.Compare(a.Field, b.Field) })
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("References() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStages(t *testing.T) {
+	tests := []struct {
+		name  string
+		graph *Graph
+		ids   []string
+		want  [][]string
+	}{
+		{
+			name: "asset, configuration, activation",
+			graph: graphOf(
+				activation("act", "mail"),
+				mail("mail", "cred"),
+				decl(typeCredential, "cred", nil),
+			),
+			ids:  []string{"act", "mail", "cred"},
+			want: [][]string{{"cred"}, {"mail"}, {"act"}},
+		},
+		{
+			name: "within a class by references",
+			graph: graphOf(
+				activation("act", "mail", "pass"),
+				mail("mail", "cred"),
+				decl(typePasscode, "pass", nil),
+				decl(typeCredential, "cred", nil),
+			),
+			ids:  []string{"act", "mail", "pass", "cred"},
+			want: [][]string{{"cred"}, {"pass"}, {"mail"}, {"act"}},
+		},
+		{
+			name: "missing target comes first",
+			graph: graphOf(
+				activation("act", "pass", "gone"),
+				decl(typePasscode, "pass", nil),
+			),
+			ids:  []string{"act", "pass", "gone"},
+			want: [][]string{{"gone"}, {"pass"}, {"act"}},
+		},
+		{
+			name: "only the given identifiers",
+			graph: graphOf(
+				activation("act", "pass"),
+				decl(typePasscode, "pass", nil),
+			),
+			ids:  []string{"act"},
+			want: [][]string{{"act"}},
+		},
+		{
+			name: "declarations of a stage are sorted",
+			graph: graphOf(
+				decl(typePasscode, "b", nil),
+				decl(typePasscode, "a", nil),
+			),
+			ids:  []string{"b", "a"},
+			want: [][]string{{"a", "b"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.graph.Stages(tt.ids); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Stages() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStagesCycle(t *testing.T) {
+	// References are built by hand, no declaration types refer to each other
+	// in a cycle
+	g := &Graph{
+		Declarations: map[string]map[string]any{
+			"a": decl(typePasscode, "a", nil),
+			"b": decl(typePasscode, "b", nil),
+			"c": decl(typePasscode, "c", nil),
+		},
+		References: map[string][]Reference{
+			"a": {{From: "a", To: "b", Class: ClassConfiguration}},
+			"b": {{From: "b", To: "a", Class: ClassConfiguration}},
+			"c": {{From: "c", To: "a", Class: ClassConfiguration}},
+		},
+	}
+	stages := g.Stages([]string{"a", "b", "c"})
+	var got []string
+	for _, stage := range stages {
+		got = append(got, stage...)
+	}
+	slices.Sort(got)
+	if want := []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("Stages() = %v, want every declaration once", stages)
+	}
+	// c refers into the cycle, so it comes after both of its members
+	if last := stages[len(stages)-1]; !slices.Contains(last, "c") {
+		t.Errorf("Stages() = %v, want c last", stages)
+	}
+}
+
+func TestProblems(t *testing.T) {
+	g := graphOf(
+		activation("act", "pass", "gone", "cred"),
+		decl(typePasscode, "pass", nil),
+		decl(typeCredential, "cred", nil),
+	)
+	g.Sets = map[string][]string{"default": {"act", "cred"}}
+	var got []string
+	for _, p := range g.Problems([]string{"act"}) {
+		got = append(got, p.String())
+	}
+	want := []string{
+		"act: Payload.StandardConfigurations[0] refers to pass, which is not in set default",
+		"act: Payload.StandardConfigurations[1] refers to gone, which does not exist",
+		"act: Payload.StandardConfigurations[2] refers to cred of type com.apple.asset.credential.userpassword, which is not in the configuration class",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Problems() = %q, want %q", got, want)
+	}
+}
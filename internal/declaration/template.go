@@ -0,0 +1,67 @@
+package declaration
+
+import (
+	"bytes"
+	"fmt"
+	"maps"
+	"strings"
+	"text/template"
+
+	"github.com/macadmins/nanohubctl/internal/output"
+)
+
+// Render executes a declaration file as a Go template with values. Referring
+// to a value that is not set is an error. Files without template actions are
+// returned unchanged.
+func Render(path string, b []byte, values map[string]any) ([]byte, error) {
+	if !bytes.Contains(b, []byte("{{")) {
+		return b, nil
+	}
+	tmpl, err := template.New(path).Funcs(output.TemplateFuncs).Option("missingkey=error").Parse(string(b))
+	if err != nil {
+		return nil, err
+	}
+	if values == nil {
+		values = map[string]any{}
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, values); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// MergeValues deep merges src into dst, values in src win
+func MergeValues(dst, src map[string]any) {
+	for k, v := range src {
+		if sv, ok := v.(map[string]any); ok {
+			if dv, ok := dst[k].(map[string]any); ok {
+				MergeValues(dv, sv)
+				continue
+			}
+			v = maps.Clone(sv)
+		}
+		dst[k] = v
+	}
+}
+
+// SetValue parses a key=value assignment, with dots in the key for nested
+// values, and sets it in values
+func SetValue(values map[string]any, assignment string) error {
+	key, value, ok := strings.Cut(assignment, "=")
+	if !ok || key == "" {
+		return fmt.Errorf("%q must be of the form key=value", assignment)
+	}
+	parts := strings.Split(key, ".")
+	m := values
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = map[string]any{}
+			m[part] = next
+		}
+		m = next
+	}
+	m[parts[len(parts)-1]] = value
+	return nil
+}
@@ -0,0 +1,239 @@
+// Package declaration reads and checks DDM declaration files before they are
+// uploaded to NanoHUB.
+package declaration
+
+//go:generate go run genenums.go
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strings"
+
+	"github.com/korylprince/go-adm/declarations"
+)
+
+// Error is a problem with one field of a declaration file
+type Error struct {
+	File string
+	// Field is the path of the offending field, e.g. Payload.MinimumLength,
+	// empty if the problem is with the file as a whole
+	Field   string
+	Message string
+}
+
+func (e *Error) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("%s: %s", e.File, e.Message)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.File, e.Field, e.Message)
+}
+
+// Validate checks a JSON encoded declaration read from file against the
+// go-adm schema of its Type: the Identifier, Type and required fields are
+// present and every known field has the right type and an allowed value.
+// Fields the schema does not know are left alone so declarations for newer OS
+// versions still pass. The returned error joins an *Error per problem.
+func Validate(file string, declJSON []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(declJSON))
+	dec.UseNumber()
+	var decl any
+	if err := dec.Decode(&decl); err != nil {
+		return &Error{File: file, Message: fmt.Sprintf("invalid JSON: %v", err)}
+	}
+	obj, ok := decl.(map[string]any)
+	if !ok {
+		return &Error{File: file, Message: "declaration must be a JSON object"}
+	}
+
+	v := &validator{file: file}
+	if id, ok := obj["Identifier"].(string); !ok || id == "" {
+		v.fail("Identifier", "required string is missing")
+	}
+	typ, ok := obj["Type"].(string)
+	if !ok || typ == "" {
+		v.fail("Type", "required string is missing")
+		return v.err()
+	}
+	schema, ok := declarations.DeclarationMap[typ]
+	if !ok {
+		v.fail("Type", fmt.Sprintf("unknown declaration type %q", typ))
+		return v.err()
+	}
+	if token, ok := obj["ServerToken"]; ok {
+		if _, ok := token.(string); !ok {
+			v.fail("ServerToken", "must be a string")
+		}
+	}
+	payload, ok := obj["Payload"]
+	if !ok {
+		v.fail("Payload", "required field is missing")
+		return v.err()
+	}
+	v.check("Payload", reflect.TypeOf(schema), payload)
+	return v.err()
+}
+
+type validator struct {
+	file string
+	errs []error
+}
+
+func (v *validator) fail(field, msg string) {
+	v.errs = append(v.errs, &Error{File: v.file, Field: field, Message: msg})
+}
+
+func (v *validator) err() error {
+	return errors.Join(v.errs...)
+}
+
+// check validates value against the Go type t that go-adm decodes field into
+func (v *validator) check(field string, t reflect.Type, value any) {
+	if value == nil {
+		switch t.Kind() {
+		case reflect.Pointer, reflect.Slice, reflect.Map, reflect.Interface:
+		default:
+			v.fail(field, fmt.Sprintf("must be %s, not null", kindName(t)))
+		}
+		return
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Interface:
+	case reflect.String:
+		s, ok := value.(string)
+		if !ok {
+			v.fail(field, fmt.Sprintf("must be a string, not %s", valueName(value)))
+			return
+		}
+		if allowed, ok := enums[t.PkgPath()+"."+t.Name()]; ok && !slices.Contains(allowed, s) {
+			v.fail(field, fmt.Sprintf("%q is not one of %s", s, strings.Join(allowed, ", ")))
+		}
+	case reflect.Bool:
+		if _, ok := value.(bool); !ok {
+			v.fail(field, fmt.Sprintf("must be a boolean, not %s", valueName(value)))
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, ok := value.(json.Number)
+		if !ok {
+			v.fail(field, fmt.Sprintf("must be an integer, not %s", valueName(value)))
+			return
+		}
+		if _, err := n.Int64(); err != nil {
+			v.fail(field, fmt.Sprintf("must be an integer, not %s", n))
+		}
+	case reflect.Float32, reflect.Float64:
+		if _, ok := value.(json.Number); !ok {
+			v.fail(field, fmt.Sprintf("must be a number, not %s", valueName(value)))
+		}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			// []byte is base64 encoded data
+			if _, ok := value.(string); !ok {
+				v.fail(field, fmt.Sprintf("must be base64 encoded data, not %s", valueName(value)))
+			}
+			return
+		}
+		items, ok := value.([]any)
+		if !ok {
+			v.fail(field, fmt.Sprintf("must be an array, not %s", valueName(value)))
+			return
+		}
+		for i, item := range items {
+			v.check(fmt.Sprintf("%s[%d]", field, i), t.Elem(), item)
+		}
+	case reflect.Map:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.fail(field, fmt.Sprintf("must be an object, not %s", valueName(value)))
+			return
+		}
+		for _, k := range sortedKeys(obj) {
+			v.check(field+"."+k, t.Elem(), obj[k])
+		}
+	case reflect.Struct:
+		obj, ok := value.(map[string]any)
+		if !ok {
+			v.fail(field, fmt.Sprintf("must be an object, not %s", valueName(value)))
+			return
+		}
+		v.checkStruct(field, t, obj)
+	}
+}
+
+func (v *validator) checkStruct(field string, t reflect.Type, obj map[string]any) {
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name := jsonName(f)
+		if name == "" {
+			continue
+		}
+		value, ok := obj[name]
+		if !ok {
+			if f.Tag.Get("required") == "true" {
+				v.fail(field+"."+name, "required field is missing")
+			}
+			continue
+		}
+		v.check(field+"."+name, f.Type, value)
+	}
+}
+
+// jsonName returns the JSON key of a struct field, empty if it is not encoded
+func jsonName(f reflect.StructField) string {
+	if !f.IsExported() {
+		return ""
+	}
+	name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+	switch name {
+	case "-":
+		return ""
+	case "":
+		return f.Name
+	}
+	return name
+}
+
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	case reflect.Struct:
+		return "an object"
+	}
+	return "a number"
+}
+
+func valueName(value any) string {
+	switch value.(type) {
+	case string:
+		return "a string"
+	case bool:
+		return "a boolean"
+	case json.Number:
+		return "a number"
+	case []any:
+		return "an array"
+	case map[string]any:
+		return "an object"
+	}
+	return "null"
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
@@ -0,0 +1,138 @@
+package declaration
+
+import (
+	"strings"
+	"testing"
+)
+
+const validMail = `{
+	"Type": "com.apple.configuration.account.mail",
+	"Identifier": "mail",
+	"Payload": {
+		"IncomingServer": {"ServerType": "IMAP", "HostName": "imap.example.com", "AuthenticationMethod": "Password"},
+		"OutgoingServer": {"HostName": "smtp.example.com", "AuthenticationMethod": "Password"}
+	}
+}`
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name string
+		decl string
+		// want are the messages of the joined errors, none if it is valid
+		want []string
+	}{
+		{
+			name: "valid",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass", "ServerToken": "1", "Payload": {"MinimumLength": 6, "RequireAlphanumericPasscode": true}}`,
+		},
+		{
+			name: "invalid JSON",
+			decl: `{"Type":`,
+			want: []string{"decl.json: invalid JSON: unexpected EOF"},
+		},
+		{
+			name: "not an object",
+			decl: `[]`,
+			want: []string{"decl.json: declaration must be a JSON object"},
+		},
+		{
+			name: "missing Identifier and Type",
+			decl: `{"Payload": {}}`,
+			want: []string{
+				"decl.json:Identifier: required string is missing",
+				"decl.json:Type: required string is missing",
+			},
+		},
+		{
+			name: "unknown type",
+			decl: `{"Type": "com.example.custom", "Identifier": "custom", "Payload": {}}`,
+			want: []string{`decl.json:Type: unknown declaration type "com.example.custom"`},
+		},
+		{
+			name: "ServerToken not a string",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass", "ServerToken": 1, "Payload": {}}`,
+			want: []string{"decl.json:ServerToken: must be a string"},
+		},
+		{
+			name: "missing Payload",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass"}`,
+			want: []string{"decl.json:Payload: required field is missing"},
+		},
+		{
+			name: "unknown fields are left alone",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass", "Payload": {"NewInOS99": [1, "a"]}}`,
+		},
+		{
+			name: "not an integer",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass", "Payload": {"MinimumLength": 6.5, "MaximumFailedAttempts": "6"}}`,
+			want: []string{
+				"decl.json:Payload.MinimumLength: must be an integer, not 6.5",
+				"decl.json:Payload.MaximumFailedAttempts: must be an integer, not a string",
+			},
+		},
+		{
+			name: "not a boolean",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass", "Payload": {"RequirePasscode": "yes"}}`,
+			want: []string{"decl.json:Payload.RequirePasscode: must be a boolean, not a string"},
+		},
+		{
+			name: "nested struct",
+			decl: validMail,
+		},
+		{
+			name: "required field of a nested struct",
+			decl: strings.Replace(validMail, `"HostName": "imap.example.com", `, "", 1),
+			want: []string{"decl.json:Payload.IncomingServer.HostName: required field is missing"},
+		},
+		{
+			name: "null required field",
+			decl: strings.Replace(validMail, `"imap.example.com"`, "null", 1),
+			want: []string{"decl.json:Payload.IncomingServer.HostName: must be a string, not null"},
+		},
+		{
+			name: "nested struct not an object",
+			decl: `{"Type": "com.apple.configuration.passcode.settings", "Identifier": "pass", "Payload": {"CustomRegex": "^a$"}}`,
+			want: []string{"decl.json:Payload.CustomRegex: must be an object, not a string"},
+		},
+		{
+			name: "enum value",
+			decl: strings.Replace(validMail, `"IMAP"`, `"SMTP"`, 1),
+			want: []string{`decl.json:Payload.IncomingServer.ServerType: "SMTP" is not one of IMAP, POP`},
+		},
+		{
+			name: "slice",
+			decl: `{"Type": "com.apple.activation.simple", "Identifier": "act", "Payload": {"StandardConfigurations": ["pass"]}}`,
+		},
+		{
+			name: "slice item",
+			decl: `{"Type": "com.apple.activation.simple", "Identifier": "act", "Payload": {"StandardConfigurations": ["pass", 1]}}`,
+			want: []string{"decl.json:Payload.StandardConfigurations[1]: must be a string, not a number"},
+		},
+		{
+			name: "not a slice",
+			decl: `{"Type": "com.apple.activation.simple", "Identifier": "act", "Payload": {"StandardConfigurations": "pass"}}`,
+			want: []string{"decl.json:Payload.StandardConfigurations: must be an array, not a string"},
+		},
+		{
+			name: "map",
+			decl: `{"Type": "com.apple.management.server-capabilities", "Identifier": "caps", "Payload": {"Version": "1", "SupportedFeatures": {"a": 1}}}`,
+		},
+		{
+			name: "not a map",
+			decl: `{"Type": "com.apple.management.server-capabilities", "Identifier": "caps", "Payload": {"Version": "1", "SupportedFeatures": []}}`,
+			want: []string{"decl.json:Payload.SupportedFeatures: must be an object, not an array"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Validate("decl.json", []byte(tt.decl))
+			var got []string
+			if err != nil {
+				got = strings.Split(err.Error(), "\n")
+			}
+			if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+				t.Errorf("Validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package output
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/itchyny/gojq"
+)
+
+// hyphenatedField matches field access like .operating-system, which jq reads
+// as a subtraction
+var hyphenatedField = regexp.MustCompile(`\.([A-Za-z_][A-Za-z0-9_]*(?:-[A-Za-z0-9_]+)+)`)
+
+// CompileJQ parses and compiles a jq expression. DDM status item names contain
+// hyphens, so hyphenated field names outside string literals are quoted first:
+// .device.operating-system.version works like .device."operating-system".version.
+// To subtract, put spaces around the minus: .a - 1.
+func CompileJQ(expr string) (*gojq.Code, error) {
+	code, err := compileJQ(quoteHyphenatedFields(expr))
+	if err != nil {
+		return nil, fmt.Errorf("invalid jq expression %q: %w", expr, err)
+	}
+	return code, nil
+}
+
+// quoteHyphenatedFields quotes the hyphenated field names in expr, leaving
+// string literals alone apart from the expressions interpolated with \(...)
+func quoteHyphenatedFields(expr string) string {
+	var b strings.Builder
+	// parens holds the open parentheses of each interpolation being scanned
+	var parens []int
+	inString := false
+	start := 0
+	code := func(end int) {
+		b.WriteString(hyphenatedField.ReplaceAllString(expr[start:end], `."$1"`))
+		start = end
+	}
+	literal := func(end int) {
+		b.WriteString(expr[start:end])
+		start = end
+	}
+	for i := 0; i < len(expr); i++ {
+		if inString {
+			switch expr[i] {
+			case '\\':
+				if i+1 < len(expr) && expr[i+1] == '(' {
+					literal(i + 2)
+					parens = append(parens, 0)
+					inString = false
+				}
+				i++
+			case '"':
+				literal(i + 1)
+				inString = false
+			}
+			continue
+		}
+		switch expr[i] {
+		case '"':
+			code(i)
+			inString = true
+		case '(':
+			if len(parens) > 0 {
+				parens[len(parens)-1]++
+			}
+		case ')':
+			if len(parens) == 0 {
+				break
+			}
+			if parens[len(parens)-1] > 0 {
+				parens[len(parens)-1]--
+				break
+			}
+			// The end of an interpolation, back in the string
+			code(i)
+			parens = parens[:len(parens)-1]
+			inString = true
+		}
+	}
+	if inString {
+		literal(len(expr))
+	} else {
+		code(len(expr))
+	}
+	return b.String()
+}
+
+func compileJQ(expr string) (*gojq.Code, error) {
+	query, err := gojq.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+	return gojq.Compile(query)
+}
+
+// runJQ applies expr to data and returns every value it emits
+func runJQ(expr string, data any) ([]any, error) {
+	code, err := CompileJQ(expr)
+	if err != nil {
+		return nil, err
+	}
+	input, err := Generic(data)
+	if err != nil {
+		return nil, err
+	}
+	var results []any
+	iter := code.Run(input)
+	for {
+		v, ok := iter.Next()
+		if !ok {
+			break
+		}
+		if err, ok := v.(error); ok {
+			if err, ok := err.(*gojq.HaltError); ok && err.Value() == nil {
+				break
+			}
+			return nil, fmt.Errorf("jq: %w", err)
+		}
+		results = append(results, v)
+	}
+	return results, nil
+}
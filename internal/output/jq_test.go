@@ -0,0 +1,140 @@
+package output
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRunJQ(t *testing.T) {
+	data := map[string]any{
+		"device": map[string]any{
+			"operating-system": map[string]any{"version": "15.1", "build-version": "24B83"},
+			"model":            map[string]any{"family": "Mac"},
+		},
+		"sets":       []string{"default", "lab"},
+		"foo-length": 3,
+		"a-keys":     []string{"x"},
+		"n":          5,
+	}
+	tests := []struct {
+		expr string
+		want []any
+	}{
+		{".device.model.family", []any{"Mac"}},
+		{".sets[]", []any{"default", "lab"}},
+		{".sets | length", []any{2}},
+		{`.device."operating-system".version`, []any{"15.1"}},
+		{".device.operating-system.version", []any{"15.1"}},
+		{".device.operating-system.build-version", []any{"24B83"}},
+		{".device.operating-system | keys", []any{[]any{"build-version", "version"}}},
+		{".foo-length", []any{float64(3)}},
+		{".a-keys", []any{[]any{"x"}}},
+		{".n - 1", []any{float64(4)}},
+		{`.sets | map(select(. != "no.such-field"))`, []any{[]any{"default", "lab"}}},
+		{`"\(.foo-length)-\(.device.model.family)"`, []any{"3-Mac"}},
+		{"empty", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			got, err := runJQ(tt.expr, data)
+			if err != nil {
+				t.Fatalf("runJQ() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runJQ() = %#v, want %#v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuoteHyphenatedFields(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{".device.model", ".device.model"},
+		{".operating-system.build-version", `."operating-system"."build-version"`},
+		{".foo-length", `."foo-length"`},
+		{".a - 1", ".a - 1"},
+		{`select(.path == ".device.operating-system")`, `select(.path == ".device.operating-system")`},
+		{`"a\"b.c-d" + .e-f`, `"a\"b.c-d" + ."e-f"`},
+		{`"\(.a-b | (.c-d)) .e-f"`, `"\(."a-b" | (."c-d")) .e-f"`},
+		{`"unterminated .a-b`, `"unterminated .a-b`},
+	}
+	for _, tt := range tests {
+		if got := quoteHyphenatedFields(tt.expr); got != tt.want {
+			t.Errorf("quoteHyphenatedFields(%s) = %s, want %s", tt.expr, got, tt.want)
+		}
+	}
+}
+
+func TestRunJQErrors(t *testing.T) {
+	tests := []struct {
+		expr string
+		want string
+	}{
+		{".[", `invalid jq expression ".["`},
+		{".sets | foo", `invalid jq expression ".sets | foo"`},
+		{".sets.x", "jq: expected an object"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := runJQ(tt.expr, map[string]any{"sets": []string{}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("runJQ() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintJQ(t *testing.T) {
+	data := []map[string]any{{"identifier": "a", "active": true}, {"identifier": "b", "active": false}}
+	tests := []struct {
+		name   string
+		expr   string
+		format string
+		want   string
+	}{
+		{
+			name: "strings are printed raw",
+			expr: ".[].identifier",
+			want: "a\nb\n",
+		},
+		{
+			name: "other values as JSON",
+			expr: ".[0]",
+			want: "{\n\t\"active\": true,\n\t\"identifier\": \"a\"\n}\n",
+		},
+		{
+			name:   "a single result is rendered on its own",
+			expr:   "map(select(.active))",
+			format: Table,
+			want:   "ACTIVE  IDENTIFIER\ntrue    a\n",
+		},
+		{
+			name:   "several results are rendered as a list",
+			expr:   ".[].identifier",
+			format: JSON,
+			want:   "[\n\t\"a\",\n\t\"b\"\n]\n",
+		},
+		{
+			name:   "no results",
+			expr:   ".[] | select(.identifier == \"c\")",
+			format: JSON,
+			want:   "[]\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, map[string]string{"jq": tt.expr, "output": tt.format})
+			var out strings.Builder
+			if err := Print(&out, Result{Data: data}); err != nil {
+				t.Fatalf("Print() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Print() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
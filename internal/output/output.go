@@ -0,0 +1,399 @@
+// Package output renders command results in the format selected with
+// --output.
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// Supported --output formats
+const (
+	JSON  = "json"
+	YAML  = "yaml"
+	Table = "table"
+	CSV   = "csv"
+	Name  = "name"
+)
+
+// Formats lists the supported --output formats
+var Formats = []string{JSON, YAML, Table, CSV, Name}
+
+// Result is the value a read command renders
+type Result struct {
+	// Data is what json and yaml emit
+	Data any
+	// Columns and Rows are used for table and csv. When nil they are derived
+	// from Data.
+	Columns []string
+	Rows    [][]string
+	// KeyColumn names the column holding map keys when rows are derived from
+	// a map of lists, such as the per-enrollment status reports
+	KeyColumn string
+	// Names are printed one per line for name. When nil they are derived
+	// from Data.
+	Names []string
+	// Default is the format used when --output and --jq are not set, json
+	// if empty
+	Default string
+}
+
+// Format returns the --output format for r
+func (r Result) Format() string {
+	if format := viper.GetString("output"); format != "" {
+		return format
+	}
+	if r.Default != "" {
+		return r.Default
+	}
+	return JSON
+}
+
+// Print renders r to w, after applying the --jq expression if one is set.
+// --template takes precedence over --output.
+func Print(w io.Writer, r Result) error {
+	tmpl, err := Template()
+	if err != nil {
+		return err
+	}
+	if expr := viper.GetString("jq"); expr != "" {
+		results, err := runJQ(expr, r.Data)
+		if err != nil {
+			return err
+		}
+		if viper.GetString("output") == "" && tmpl == nil {
+			// Like jq -r, print each result on its own line with strings unquoted
+			for _, v := range results {
+				if s, ok := v.(string); ok {
+					fmt.Fprintln(w, s)
+				} else if err := printJSON(w, v); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+		// Columns and names no longer apply to the filtered data
+		r = Result{Data: results}
+		if len(results) == 1 {
+			r.Data = results[0]
+		} else if results == nil {
+			r.Data = []any{}
+		}
+	}
+	if tmpl != nil {
+		return printTemplate(w, tmpl, r.Data)
+	}
+
+	switch format := r.Format(); format {
+	case JSON:
+		return printJSON(w, r.Data)
+	case YAML:
+		return printYAML(w, r.Data)
+	case Table:
+		columns, rows, err := r.table()
+		if err != nil {
+			return err
+		}
+		return printTable(w, columns, rows)
+	case CSV:
+		columns, rows, err := r.table()
+		if err != nil {
+			return err
+		}
+		return printCSV(w, columns, rows)
+	case Name:
+		names, err := r.names()
+		if err != nil {
+			return err
+		}
+		for _, name := range names {
+			fmt.Fprintln(w, name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown output format %q, valid formats are: %s", format, strings.Join(Formats, ", "))
+	}
+}
+
+func printJSON(w io.Writer, data any) error {
+	s, err := json.MarshalIndent(data, "", "\t")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintln(w, string(s))
+	return err
+}
+
+func printYAML(w io.Writer, data any) error {
+	// Go through JSON so struct tags and json.RawMessage behave the same as for json
+	generic, err := Generic(data)
+	if err != nil {
+		return err
+	}
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(generic); err != nil {
+		return err
+	}
+	return enc.Close()
+}
+
+func printTable(w io.Writer, columns []string, rows [][]string) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	headers := make([]string, len(columns))
+	for i, c := range columns {
+		headers[i] = strings.ToUpper(c)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+func printCSV(w io.Writer, columns []string, rows [][]string) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	if err := cw.WriteAll(rows); err != nil {
+		return err
+	}
+	return cw.Error()
+}
+
+// Generic converts data to the plain maps, slices and scalars JSON decodes to
+func Generic(data any) (any, error) {
+	b, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var generic any
+	if err := json.Unmarshal(b, &generic); err != nil {
+		return nil, err
+	}
+	return generic, nil
+}
+
+// table returns the columns and rows for table and csv output
+func (r Result) table() ([]string, [][]string, error) {
+	if r.Columns != nil {
+		return r.Columns, r.Rows, nil
+	}
+	generic, err := Generic(r.Data)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyColumn := r.KeyColumn
+	if keyColumn == "" {
+		keyColumn = "key"
+	}
+
+	switch v := generic.(type) {
+	case []any:
+		return listTable(v, "", nil)
+	case map[string]any:
+		if !mapOfLists(v) {
+			var rows [][]string
+			for _, k := range sortedKeys(v) {
+				rows = append(rows, []string{k, Cell(v[k])})
+			}
+			return []string{keyColumn, "value"}, rows, nil
+		}
+		// One row per list item, prefixed with the map key
+		var columns []string
+		var rows [][]string
+		for _, k := range sortedKeys(v) {
+			cols, krows, err := listTable(v[k].([]any), keyColumn, []string{k})
+			if err != nil {
+				return nil, nil, err
+			}
+			columns = mergeColumns(columns, cols)
+			rows = append(rows, alignRows(cols, columns, krows)...)
+		}
+		// Earlier rows may lack columns later items introduced
+		for i := range rows {
+			for len(rows[i]) < len(columns) {
+				rows[i] = append(rows[i], "")
+			}
+		}
+		if columns == nil {
+			columns = []string{keyColumn}
+		}
+		return columns, rows, nil
+	}
+	return []string{"value"}, [][]string{{Cell(generic)}}, nil
+}
+
+// listTable derives columns from the union of the keys of the objects in
+// list, or a single value column for a list of scalars. With prefixColumn
+// set, every row starts with prefix.
+func listTable(list []any, prefixColumn string, prefix []string) ([]string, [][]string, error) {
+	var columns []string
+	if prefixColumn != "" {
+		columns = append(columns, prefixColumn)
+	}
+	var keys []string
+	objects := len(list) > 0
+	for _, item := range list {
+		obj, ok := item.(map[string]any)
+		if !ok {
+			objects = false
+			break
+		}
+		for k := range obj {
+			if !slices.Contains(keys, k) {
+				keys = append(keys, k)
+			}
+		}
+	}
+	sort.Strings(keys)
+
+	var rows [][]string
+	if !objects {
+		columns = append(columns, "value")
+		for _, item := range list {
+			rows = append(rows, append(slices.Clone(prefix), Cell(item)))
+		}
+		return columns, rows, nil
+	}
+	columns = append(columns, keys...)
+	for _, item := range list {
+		obj := item.(map[string]any)
+		row := slices.Clone(prefix)
+		for _, k := range keys {
+			row = append(row, Cell(obj[k]))
+		}
+		rows = append(rows, row)
+	}
+	return columns, rows, nil
+}
+
+func mergeColumns(columns, add []string) []string {
+	for _, c := range add {
+		if !slices.Contains(columns, c) {
+			columns = append(columns, c)
+		}
+	}
+	return columns
+}
+
+// alignRows reorders rows laid out as from into the order of to
+func alignRows(from, to []string, rows [][]string) [][]string {
+	aligned := make([][]string, len(rows))
+	for i, row := range rows {
+		out := make([]string, len(to))
+		for j, c := range from {
+			out[slices.Index(to, c)] = row[j]
+		}
+		aligned[i] = out
+	}
+	return aligned
+}
+
+func mapOfLists(m map[string]any) bool {
+	if len(m) == 0 {
+		return false
+	}
+	for _, v := range m {
+		if _, ok := v.([]any); !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]any) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// Cell formats a value for a table or csv cell. Scalars are printed as is,
+// anything else as compact JSON.
+func Cell(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64, bool, json.Number:
+		return fmt.Sprint(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// names returns the values printed for name output
+func (r Result) names() ([]string, error) {
+	if r.Names != nil {
+		return r.Names, nil
+	}
+	generic, err := Generic(r.Data)
+	if err != nil {
+		return nil, err
+	}
+	switch v := generic.(type) {
+	case []any:
+		var names []string
+		for _, item := range v {
+			names = append(names, itemName(item))
+		}
+		return names, nil
+	case map[string]any:
+		if name := itemName(v); name != "" {
+			return []string{name}, nil
+		}
+		return sortedKeys(v), nil
+	}
+	return []string{Cell(generic)}, nil
+}
+
+// itemName picks the identifying field of an object
+func itemName(item any) string {
+	obj, ok := item.(map[string]any)
+	if !ok {
+		return Cell(item)
+	}
+	for _, k := range []string{"Identifier", "identifier", "Name", "name"} {
+		if s, ok := obj[k].(string); ok {
+			return s
+		}
+	}
+	return ""
+}
+
+// List is the Result for a plain list of names, such as identifiers or set
+// names, rendered as a single table column
+func List(column string, values []string) Result {
+	if values == nil {
+		values = []string{}
+	}
+	rows := make([][]string, len(values))
+	for i, v := range values {
+		rows[i] = []string{v}
+	}
+	return Result{Data: values, Columns: []string{column}, Rows: rows, Names: values}
+}
+
+// Requested reports whether machine readable output was asked for with
+// --output, --jq or --template
+func Requested() bool {
+	return viper.GetString("output") != "" || viper.GetString("jq") != "" ||
+		viper.GetString("template") != "" || viper.GetString("template_file") != ""
+}
@@ -0,0 +1,158 @@
+package output
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// setFlags sets global flags for the duration of a test
+func setFlags(t *testing.T, flags map[string]string) {
+	t.Helper()
+	for k, v := range flags {
+		viper.Set(k, v)
+	}
+	t.Cleanup(func() {
+		for k := range flags {
+			viper.Set(k, "")
+		}
+	})
+}
+
+type device struct {
+	Serial string   `json:"serial"`
+	Model  string   `json:"model,omitempty"`
+	Sets   []string `json:"sets"`
+}
+
+func TestPrint(t *testing.T) {
+	devices := []device{
+		{Serial: "C02A", Model: "MacBookPro18,1", Sets: []string{"default", "lab"}},
+		{Serial: "C02B", Sets: []string{}},
+	}
+	status := map[string][]map[string]any{
+		"enrollment-b": {{"identifier": "pass", "valid": "valid"}},
+		"enrollment-a": {{"identifier": "act", "active": true}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		result Result
+		want   string
+	}{
+		{
+			name:   "json is the default",
+			result: Result{Data: devices[1]},
+			want:   "{\n\t\"serial\": \"C02B\",\n\t\"sets\": []\n}\n",
+		},
+		{
+			name:   "result default",
+			result: Result{Data: []string{"a", "b"}, Default: Name},
+			want:   "a\nb\n",
+		},
+		{
+			name:   "yaml",
+			format: YAML,
+			result: Result{Data: devices[:1]},
+			want:   "- model: MacBookPro18,1\n  serial: C02A\n  sets:\n    - default\n    - lab\n",
+		},
+		{
+			name:   "table from a list of objects",
+			format: Table,
+			result: Result{Data: devices},
+			want: "MODEL           SERIAL  SETS\n" +
+				"MacBookPro18,1  C02A    [\"default\",\"lab\"]\n" +
+				"                C02B    []\n",
+		},
+		{
+			name:   "table with explicit columns",
+			format: Table,
+			result: List("identifier", []string{"com.example.a", "b"}),
+			want:   "IDENTIFIER\ncom.example.a\nb\n",
+		},
+		{
+			name:   "table from an object",
+			format: Table,
+			result: Result{Data: map[string]any{"b": 2, "a": "x"}},
+			want:   "KEY  VALUE\na    x\nb    2\n",
+		},
+		{
+			name:   "table from a map of lists",
+			format: Table,
+			result: Result{Data: status, KeyColumn: "enrollment"},
+			want: "ENROLLMENT    ACTIVE  IDENTIFIER  VALID\n" +
+				"enrollment-a  true    act         \n" +
+				"enrollment-b          pass        valid\n",
+		},
+		{
+			name:   "table from a scalar",
+			format: Table,
+			result: Result{Data: "ok"},
+			want:   "VALUE\nok\n",
+		},
+		{
+			name:   "csv",
+			format: CSV,
+			result: Result{Data: devices},
+			want:   "model,serial,sets\n\"MacBookPro18,1\",C02A,\"[\"\"default\"\",\"\"lab\"\"]\"\n,C02B,[]\n",
+		},
+		{
+			name:   "csv of an empty list",
+			format: CSV,
+			result: List("set", nil),
+			want:   "set\n",
+		},
+		{
+			name:   "name from identifying fields",
+			format: Name,
+			result: Result{Data: []map[string]any{{"Identifier": "a"}, {"name": "b"}, {"other": "c"}}},
+			want:   "a\nb\n\n",
+		},
+		{
+			name:   "name from map keys",
+			format: Name,
+			result: Result{Data: map[string]any{"b": 1, "a": 2}},
+			want:   "a\nb\n",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, map[string]string{"output": tt.format})
+			var out strings.Builder
+			if err := Print(&out, tt.result); err != nil {
+				t.Fatalf("Print() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Print() =\n%s\nwant\n%s", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintUnknownFormat(t *testing.T) {
+	setFlags(t, map[string]string{"output": "xml"})
+	err := Print(&strings.Builder{}, Result{Data: 1})
+	if err == nil || !strings.Contains(err.Error(), `unknown output format "xml"`) {
+		t.Errorf("Print() error = %v, want unknown output format", err)
+	}
+}
+
+func TestCell(t *testing.T) {
+	for _, tt := range []struct {
+		value any
+		want  string
+	}{
+		{nil, ""},
+		{"a b", "a b"},
+		{float64(3), "3"},
+		{true, "true"},
+		{[]any{"a", 1}, `["a",1]`},
+		{map[string]any{"a": 1}, `{"a":1}`},
+	} {
+		if got := Cell(tt.value); got != tt.want {
+			t.Errorf("Cell(%v) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
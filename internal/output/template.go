@@ -0,0 +1,144 @@
+package output
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/template"
+	"time"
+	"unicode/utf8"
+
+	"github.com/spf13/viper"
+)
+
+// TemplateFuncs are the helpers available to --template in addition to the
+// text/template builtins
+var TemplateFuncs = template.FuncMap{
+	"join":    join,
+	"default": defaultValue,
+	"date":    date,
+	"pad":     pad,
+	"padleft": padLeft,
+	"json":    toJSON,
+	"upper":   strings.ToUpper,
+	"lower":   strings.ToLower,
+}
+
+// Template parses --template or the contents of --template-file. It returns
+// nil if neither is set.
+func Template() (*template.Template, error) {
+	text := viper.GetString("template")
+	if path := viper.GetString("template_file"); path != "" {
+		if text != "" {
+			return nil, fmt.Errorf("--template and --template-file cannot be used together")
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template file: %w", err)
+		}
+		text = string(b)
+	}
+	if text == "" {
+		return nil, nil
+	}
+	tmpl, err := template.New("output").Funcs(TemplateFuncs).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid template: %w", err)
+	}
+	return tmpl, nil
+}
+
+func printTemplate(w io.Writer, tmpl *template.Template, data any) error {
+	generic, err := Generic(data)
+	if err != nil {
+		return err
+	}
+	if err := tmpl.Execute(w, generic); err != nil {
+		return fmt.Errorf("failed to render template: %w", err)
+	}
+	return nil
+}
+
+// join joins the items of list with sep: {{ join ", " .sets }}
+func join(sep string, list any) string {
+	items, ok := list.([]any)
+	if !ok {
+		return Cell(list)
+	}
+	s := make([]string, len(items))
+	for i, item := range items {
+		s[i] = Cell(item)
+	}
+	return strings.Join(s, sep)
+}
+
+// defaultValue returns value, or def if value is missing or empty:
+// {{ default "none" .server_token }}
+func defaultValue(def, value any) any {
+	switch v := value.(type) {
+	case nil:
+		return def
+	case string:
+		if v == "" {
+			return def
+		}
+	case []any:
+		if len(v) == 0 {
+			return def
+		}
+	case map[string]any:
+		if len(v) == 0 {
+			return def
+		}
+	}
+	return value
+}
+
+// date formats an RFC 3339 timestamp or Unix time with a Go time layout:
+// {{ date "2006-01-02 15:04" .status_received }}
+func date(layout string, value any) (string, error) {
+	var t time.Time
+	switch v := value.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if v == "" {
+			return "", nil
+		}
+		var err error
+		if t, err = time.Parse(time.RFC3339Nano, v); err != nil {
+			return "", fmt.Errorf("date: %w", err)
+		}
+	case float64:
+		t = time.Unix(int64(v), 0).UTC()
+	default:
+		return "", fmt.Errorf("date: cannot format %T", value)
+	}
+	return t.Format(layout), nil
+}
+
+// pad right pads value with spaces to width characters: {{ pad 40 .identifier }}
+func pad(width int, value any) string {
+	s := Cell(value)
+	if n := utf8.RuneCountInString(s); n < width {
+		s += strings.Repeat(" ", width-n)
+	}
+	return s
+}
+
+// padLeft left pads value with spaces to width characters
+func padLeft(width int, value any) string {
+	s := Cell(value)
+	if n := utf8.RuneCountInString(s); n < width {
+		s = strings.Repeat(" ", width-n) + s
+	}
+	return s
+}
+
+// toJSON encodes value as compact JSON
+func toJSON(value any) (string, error) {
+	b, err := json.Marshal(value)
+	return string(b), err
+}
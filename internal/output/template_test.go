@@ -0,0 +1,116 @@
+package output
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPrintTemplate(t *testing.T) {
+	data := []map[string]any{
+		{"identifier": "com.example.pass", "sets": []string{"default", "lab"}, "server_token": "abc", "received": "2024-05-01T10:30:00Z"},
+		{"identifier": "act", "sets": []string{}, "received": float64(1714559400)},
+	}
+	tests := []struct {
+		name string
+		tmpl string
+		want string
+	}{
+		{
+			name: "fields and range",
+			tmpl: "{{range .}}{{.identifier}}\n{{end}}",
+			want: "com.example.pass\nact\n",
+		},
+		{
+			name: "join",
+			tmpl: `{{range .}}{{join "," .sets}};{{end}}`,
+			want: "default,lab;;",
+		},
+		{
+			name: "default",
+			tmpl: `{{range .}}{{default "none" .server_token}} {{default "-" .sets}}|{{end}}`,
+			want: `abc [default lab]|none -|`,
+		},
+		{
+			name: "date from RFC 3339 and Unix time",
+			tmpl: `{{range .}}{{date "2006-01-02 15:04" .received}}|{{end}}`,
+			want: "2024-05-01 10:30|2024-05-01 10:30|",
+		},
+		{
+			name: "pad and padleft",
+			tmpl: `{{range .}}[{{pad 6 .identifier}}|{{padleft 6 .identifier}}]{{end}}`,
+			want: "[com.example.pass|com.example.pass][act   |   act]",
+		},
+		{
+			name: "json, upper and lower",
+			tmpl: `{{json (index . 0).sets}} {{upper "a"}}{{lower "B"}}`,
+			want: `["default","lab"] Ab`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, map[string]string{"template": tt.tmpl})
+			var out strings.Builder
+			if err := Print(&out, Result{Data: data}); err != nil {
+				t.Fatalf("Print() error = %v", err)
+			}
+			if out.String() != tt.want {
+				t.Errorf("Print() = %q, want %q", out.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestTemplateFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tmpl")
+	if err := os.WriteFile(path, []byte("{{.name}}\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	setFlags(t, map[string]string{"template_file": path})
+	var out strings.Builder
+	if err := Print(&out, Result{Data: map[string]string{"name": "a"}}); err != nil {
+		t.Fatalf("Print() error = %v", err)
+	}
+	if out.String() != "a\n" {
+		t.Errorf("Print() = %q, want %q", out.String(), "a\n")
+	}
+}
+
+func TestTemplateErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		flags map[string]string
+		want  string
+	}{
+		{
+			name:  "both template flags",
+			flags: map[string]string{"template": "{{.}}", "template_file": "tmpl"},
+			want:  "--template and --template-file cannot be used together",
+		},
+		{
+			name:  "missing file",
+			flags: map[string]string{"template_file": filepath.Join(t.TempDir(), "missing")},
+			want:  "failed to read template file",
+		},
+		{
+			name:  "parse error",
+			flags: map[string]string{"template": "{{.name"},
+			want:  "invalid template",
+		},
+		{
+			name:  "render error",
+			flags: map[string]string{"template": `{{date "2006" .name}}`},
+			want:  "failed to render template",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			setFlags(t, tt.flags)
+			err := Print(&strings.Builder{}, Result{Data: map[string]string{"name": "a"}})
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Print() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
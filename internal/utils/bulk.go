@@ -0,0 +1,414 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"sync"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/macadmins/nanohubctl/internal/output"
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// Outcome is what happened to one item of a mutating operation
+type Outcome string
+
+const (
+	OutcomeCreated   Outcome = "created"
+	OutcomeUpdated   Outcome = "updated"
+	OutcomeDeleted   Outcome = "deleted"
+	OutcomeUnchanged Outcome = "unchanged"
+	OutcomeFailed    Outcome = "failed"
+	// OutcomeUnknown is an item whose request was cut off by an interrupt, the
+	// server may or may not have applied it
+	OutcomeUnknown Outcome = "unknown"
+	// OutcomeNotApplied is an item that was never started
+	OutcomeNotApplied Outcome = "not applied"
+)
+
+// Task is one item of a mutating operation
+type Task struct {
+	// Resource and Action describe the item in result records, e.g.
+	// "declaration" and "put"
+	Resource string
+	Action   string
+	// Name identifies the item in result records and error output
+	Name string
+	// Source is the file the item was read from, if any
+	Source string
+	// Message is printed when Do reports a change
+	Message string
+	// UnchangedMessage is printed by Apply when Do reports no change
+	UnchangedMessage string
+	// Outcome is recorded when Do reports a change, OutcomeUpdated if empty
+	Outcome Outcome
+	// Do applies the item
+	Do func(ctx context.Context) (nanohub.Change, error)
+
+	// Key and Needs link the tasks of RunStages: a task is not applied when
+	// a task of an earlier stage whose Key is in Needs failed or was not
+	// applied, e.g. a declaration referring to one that failed to upload
+	Key   string
+	Needs []string
+}
+
+// Result is the record of one Task. With --output it is printed instead of
+// the task's messages, see PrintResults.
+type Result struct {
+	Resource string  `json:"resource"`
+	Action   string  `json:"action"`
+	Name     string  `json:"name"`
+	Source   string  `json:"source,omitempty"`
+	Outcome  Outcome `json:"outcome"`
+	// Status is the HTTP status of the response, 0 if there was none
+	Status int    `json:"status,omitempty"`
+	Error  string `json:"error,omitempty"`
+	Err    error  `json:"-"`
+}
+
+// label names the item in error output
+func (r Result) label() string {
+	if r.Source != "" {
+		return r.Source
+	}
+	return r.Name
+}
+
+func newResult(task Task, outcome Outcome) Result {
+	return Result{Resource: task.Resource, Action: task.Action, Name: task.Name, Source: task.Source, Outcome: outcome}
+}
+
+// PrintResults prints result records to w when machine readable output was
+// requested, otherwise it does nothing as the tasks' messages have already
+// been printed.
+func PrintResults(w io.Writer, results []Result) error {
+	if !output.Requested() {
+		return nil
+	}
+	if results == nil {
+		results = []Result{}
+	}
+	rows := make([][]string, len(results))
+	for i, res := range results {
+		status := ""
+		if res.Status != 0 {
+			status = strconv.Itoa(res.Status)
+		}
+		rows[i] = []string{res.Resource, res.Action, res.Name, string(res.Outcome), status, res.Error}
+	}
+	return output.Print(w, output.Result{
+		Data:    results,
+		Columns: []string{"resource", "action", "name", "outcome", "status", "error"},
+		Rows:    rows,
+	})
+}
+
+// Apply runs tasks one after the other, stopping at the first failure, and
+// prints each task's message, or the result records when machine readable
+// output was requested, to w
+func Apply(ctx context.Context, w io.Writer, tasks ...Task) error {
+	results := make([]Result, len(tasks))
+	for i, task := range tasks {
+		results[i] = newResult(task, OutcomeNotApplied)
+	}
+	var err error
+	for i, task := range tasks {
+		res := runTask(ctx, task)
+		results[i] = res
+		if res.Err != nil {
+			err = res.Err
+			break
+		}
+		if output.Requested() {
+			continue
+		}
+		if res.Outcome == OutcomeUnchanged {
+			if task.UnchangedMessage != "" {
+				fmt.Fprintln(w, task.UnchangedMessage)
+			}
+		} else if task.Message != "" {
+			fmt.Fprintln(w, task.Message)
+		}
+	}
+	if perr := PrintResults(w, results); perr != nil {
+		return perr
+	}
+	return err
+}
+
+// Plan prints what tasks would do without running them: a line per task,
+// or result records with outcome "not applied" when machine readable output
+// was requested
+func Plan(w io.Writer, tasks ...Task) error {
+	if !output.Requested() {
+		for _, task := range tasks {
+			fmt.Fprintf(w, "Would %s %s: %s\n", task.Action, task.Resource, task.Name)
+		}
+		return nil
+	}
+	results := make([]Result, len(tasks))
+	for i, task := range tasks {
+		results[i] = newResult(task, OutcomeNotApplied)
+	}
+	return PrintResults(w, results)
+}
+
+func runTask(ctx context.Context, task Task) Result {
+	change, err := task.Do(ctx)
+	var res Result
+	switch {
+	case err != nil && ctx.Err() != nil:
+		res = newResult(task, OutcomeUnknown)
+	case err != nil:
+		res = newResult(task, OutcomeFailed)
+	case !change.Changed():
+		res = newResult(task, OutcomeUnchanged)
+	case task.Outcome != "":
+		res = newResult(task, task.Outcome)
+	default:
+		res = newResult(task, OutcomeUpdated)
+	}
+	res.Status = change.StatusCode
+	if err != nil {
+		res.Err = err
+		res.Error = err.Error()
+		res.Status = nanohub.StatusCode(err)
+	}
+	return res
+}
+
+// Bulk runs tasks on a bounded pool of workers
+type Bulk struct {
+	// Op names the operation in the progress indicator and summary
+	Op string
+	// Concurrency is the number of tasks run at once, at least 1
+	Concurrency int
+
+	out      io.Writer
+	errOut   io.Writer
+	quiet    bool
+	progress io.Writer
+	mu       sync.Mutex
+}
+
+// NewBulk returns a Bulk printing to stdout, with a progress indicator when
+// stderr is a terminal. Failed and not applied items are always reported on
+// stderr. When machine readable output was requested, messages are left out
+// and the summary goes to stderr so stdout only carries the records printed
+// with PrintResults.
+func NewBulk(op string, concurrency int) *Bulk {
+	b := &Bulk{Op: op, Concurrency: concurrency, out: os.Stdout, errOut: os.Stderr}
+	if output.Requested() {
+		b.out = os.Stderr
+		b.quiet = true
+	}
+	if isatty.IsTerminal(os.Stderr.Fd()) || isatty.IsCygwinTerminal(os.Stderr.Fd()) {
+		b.progress = os.Stderr
+	}
+	return b
+}
+
+// Run applies every task and returns the results in task order. Once ctx is
+// cancelled no new tasks are started. The error summarises failures and
+// interruption, see BulkError.
+func (b *Bulk) Run(ctx context.Context, tasks []Task) ([]Result, error) {
+	return b.RunStages(ctx, [][]Task{tasks})
+}
+
+// RunStages applies the stages one after the other, the tasks of a stage at
+// the same time, and returns the results in task order with one summary for
+// all of them. A task that Needs the Key of a task that failed or was not
+// applied in an earlier stage is not applied.
+func (b *Bulk) RunStages(ctx context.Context, stages [][]Task) ([]Result, error) {
+	var tasks []Task
+	for _, stage := range stages {
+		tasks = append(tasks, stage...)
+	}
+	results := make([]Result, len(tasks))
+	for i, task := range tasks {
+		results[i] = newResult(task, OutcomeNotApplied)
+	}
+	if len(tasks) == 0 {
+		return results, nil
+	}
+
+	broken := map[string]bool{}
+	done, offset := 0, 0
+	for _, stage := range stages {
+		if ctx.Err() != nil {
+			break
+		}
+		var run []int
+		for i, task := range stage {
+			i += offset
+			if need := brokenNeed(task, broken); need != "" {
+				results[i].Err = fmt.Errorf("needs %s, which was not applied", need)
+				results[i].Error = results[i].Err.Error()
+				done++
+				b.mu.Lock()
+				b.report(task, results[i], done, len(tasks))
+				b.mu.Unlock()
+				continue
+			}
+			run = append(run, i)
+		}
+		b.runStage(ctx, tasks, run, results, &done)
+		for i := offset; i < offset+len(stage); i++ {
+			switch results[i].Outcome {
+			case OutcomeFailed, OutcomeUnknown, OutcomeNotApplied:
+				if tasks[i].Key != "" {
+					broken[tasks[i].Key] = true
+				}
+			}
+		}
+		offset += len(stage)
+	}
+	b.clearProgress()
+
+	return results, b.summarise(ctx, results)
+}
+
+// brokenNeed returns the first key task needs that is broken
+func brokenNeed(task Task, broken map[string]bool) string {
+	for _, need := range task.Needs {
+		if broken[need] {
+			return need
+		}
+	}
+	return ""
+}
+
+// runStage applies the tasks at the indexes in run on the worker pool
+func (b *Bulk) runStage(ctx context.Context, tasks []Task, run []int, results []Result, done *int) {
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(b.Concurrency, 1), len(run)) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				res := runTask(ctx, tasks[i])
+				b.mu.Lock()
+				results[i] = res
+				*done++
+				b.report(tasks[i], res, *done, len(tasks))
+				b.mu.Unlock()
+			}
+		}()
+	}
+feed:
+	for _, i := range run {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- i:
+		}
+	}
+	close(queue)
+	wg.Wait()
+}
+
+// report prints the result of a finished task and redraws the progress line
+func (b *Bulk) report(task Task, res Result, done, total int) {
+	b.clearProgress()
+	switch res.Outcome {
+	case OutcomeCreated, OutcomeUpdated, OutcomeDeleted:
+		if task.Message != "" && !b.quiet {
+			fmt.Fprintln(b.out, task.Message)
+		}
+	case OutcomeFailed:
+		// A lone failure is returned as the command's error, don't print it twice
+		if total > 1 {
+			fmt.Fprintf(b.errOut, "Error: %s: %s\n", res.label(), res.Err)
+		}
+	case OutcomeNotApplied:
+		// Skipped by RunStages because a task it needs was not applied
+		fmt.Fprintf(b.errOut, "Not applied: %s: %s\n", res.label(), res.Err)
+	}
+	if b.progress != nil {
+		fmt.Fprintf(b.progress, "%s: %d/%d", b.Op, done, total)
+	}
+}
+
+func (b *Bulk) clearProgress() {
+	if b.progress != nil {
+		fmt.Fprint(b.progress, "\r\033[K")
+	}
+}
+
+// summarise prints the outcome counts and returns the error for the run
+func (b *Bulk) summarise(ctx context.Context, results []Result) error {
+	counts := map[Outcome]int{}
+	var failed []error
+	// Items not applied because an item they need failed
+	skipped := 0
+	for _, res := range results {
+		counts[res.Outcome]++
+		switch {
+		case res.Outcome == OutcomeFailed:
+			failed = append(failed, fmt.Errorf("%s: %w", res.label(), res.Err))
+		case res.Outcome == OutcomeNotApplied && res.Err != nil:
+			skipped++
+		}
+	}
+	fmt.Fprintf(b.out, "%s: ", b.Op)
+	for _, outcome := range []Outcome{OutcomeCreated, OutcomeUpdated, OutcomeDeleted} {
+		if counts[outcome] > 0 {
+			fmt.Fprintf(b.out, "%d %s, ", counts[outcome], outcome)
+		}
+	}
+	fmt.Fprintf(b.out, "%d unchanged, %d failed", counts[OutcomeUnchanged], counts[OutcomeFailed])
+	if ctx.Err() == nil {
+		if counts[OutcomeNotApplied] > 0 {
+			fmt.Fprintf(b.out, ", %d not applied", counts[OutcomeNotApplied])
+		}
+		fmt.Fprintln(b.out)
+		return BulkError(b.Op, failed, skipped, len(results))
+	}
+
+	fmt.Fprintf(b.out, ", %d unknown, %d not applied\n", counts[OutcomeUnknown], counts[OutcomeNotApplied])
+	for _, res := range results {
+		if res.Outcome == OutcomeUnknown || res.Outcome == OutcomeNotApplied {
+			fmt.Fprintf(b.out, "  %-12s %s\n", res.Outcome+":", res.label())
+		}
+	}
+	return errors.Join(fmt.Errorf("%s interrupted: %w", b.Op, ctx.Err()), BulkError(b.Op, failed, skipped, len(results)))
+}
+
+// ForEach calls fn for every index below n on up to concurrency workers, for
+// read-only fan out such as fetching many declarations. It returns the first
+// error; once there is one, or ctx is cancelled, no new calls are started.
+func ForEach(ctx context.Context, concurrency, n int, fn func(ctx context.Context, i int) error) error {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	queue := make(chan int)
+	var wg sync.WaitGroup
+	for range min(max(concurrency, 1), n) {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range queue {
+				if err := fn(ctx, i); err != nil {
+					cancel(err)
+				}
+			}
+		}()
+	}
+feed:
+	for i := range n {
+		select {
+		case <-ctx.Done():
+			break feed
+		case queue <- i:
+		}
+	}
+	close(queue)
+	wg.Wait()
+	return context.Cause(ctx)
+}
@@ -0,0 +1,187 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// recorder builds tasks that record the order they ran in
+type recorder struct {
+	mu  sync.Mutex
+	ran []string
+}
+
+func (r *recorder) task(name, key string, err error, needs ...string) Task {
+	return Task{
+		Name:  name,
+		Key:   key,
+		Needs: needs,
+		Do: func(ctx context.Context) (nanohub.Change, error) {
+			r.mu.Lock()
+			r.ran = append(r.ran, name)
+			r.mu.Unlock()
+			if err != nil {
+				return nanohub.Change{}, err
+			}
+			return nanohub.Change{StatusCode: 204}, nil
+		},
+	}
+}
+
+func testBulk() *Bulk {
+	return &Bulk{Op: "test", Concurrency: 4, out: io.Discard, errOut: io.Discard}
+}
+
+func outcomes(results []Result) map[string]Outcome {
+	m := map[string]Outcome{}
+	for _, res := range results {
+		m[res.Name] = res.Outcome
+	}
+	return m
+}
+
+func TestRunStagesOrder(t *testing.T) {
+	r := &recorder{}
+	stages := [][]Task{
+		{r.task("asset", "asset", nil), r.task("asset2", "asset2", nil)},
+		{r.task("config", "config", nil, "asset")},
+		{r.task("activation", "activation", nil, "config")},
+	}
+	results, err := testBulk().RunStages(context.Background(), stages)
+	if err != nil {
+		t.Fatalf("RunStages() error = %v", err)
+	}
+	if len(results) != 4 {
+		t.Fatalf("RunStages() returned %d results, want 4", len(results))
+	}
+	// Tasks of a stage may run in any order, stages may not overlap
+	if len(r.ran) != 4 || r.ran[2] != "config" || r.ran[3] != "activation" {
+		t.Errorf("tasks ran in order %v", r.ran)
+	}
+	for name, outcome := range outcomes(results) {
+		if outcome != OutcomeUpdated {
+			t.Errorf("%s outcome = %s, want %s", name, outcome, OutcomeUpdated)
+		}
+	}
+}
+
+func TestRunStagesNotApplied(t *testing.T) {
+	r := &recorder{}
+	failure := errors.New("rejected")
+	stages := [][]Task{
+		// The upload of the asset fails
+		{r.task("put asset", "asset", failure), r.task("put other", "other", nil)},
+		// so neither it nor what refers to it is added to the set
+		{r.task("add asset", "asset", nil, "asset"), r.task("add other", "other", nil, "other")},
+		{r.task("put config", "config", nil, "asset")},
+		// Not applied items block their dependents too
+		{r.task("put activation", "activation", nil, "config")},
+	}
+	results, err := testBulk().RunStages(context.Background(), stages)
+
+	want := map[string]Outcome{
+		"put asset":      OutcomeFailed,
+		"put other":      OutcomeUpdated,
+		"add asset":      OutcomeNotApplied,
+		"add other":      OutcomeUpdated,
+		"put config":     OutcomeNotApplied,
+		"put activation": OutcomeNotApplied,
+	}
+	got := outcomes(results)
+	for name, outcome := range want {
+		if got[name] != outcome {
+			t.Errorf("%s outcome = %s, want %s", name, got[name], outcome)
+		}
+	}
+	for _, name := range r.ran {
+		if want[name] == OutcomeNotApplied {
+			t.Errorf("%s ran, want it skipped", name)
+		}
+	}
+	for _, res := range results {
+		if res.Name == "put config" && (res.Err == nil || res.Err.Error() != "needs asset, which was not applied") {
+			t.Errorf("put config error = %v, want it to name asset", res.Err)
+		}
+	}
+
+	var partialErr *PartialError
+	if !errors.As(err, &partialErr) {
+		t.Fatalf("RunStages() error = %v, want a PartialError", err)
+	}
+	if partialErr.Failed != 1 || partialErr.NotApplied != 3 || partialErr.Total != 6 {
+		t.Errorf("PartialError = %+v, want 1 failed and 3 not applied of 6", partialErr)
+	}
+	if !errors.Is(err, failure) {
+		t.Errorf("RunStages() error does not wrap the failure")
+	}
+	if code := ExitCode(err); code != ExitPartial {
+		t.Errorf("ExitCode() = %d, want %d", code, ExitPartial)
+	}
+}
+
+func TestRunStagesNothingApplied(t *testing.T) {
+	r := &recorder{}
+	failure := &nanohub.APIError{StatusCode: 500}
+	stages := [][]Task{
+		{r.task("put asset", "asset", failure)},
+		{r.task("put config", "config", nil, "asset")},
+	}
+	_, err := testBulk().RunStages(context.Background(), stages)
+
+	var partialErr *PartialError
+	if !errors.As(err, &partialErr) || partialErr.Applied() {
+		t.Fatalf("RunStages() error = %v, want a PartialError with nothing applied", err)
+	}
+	// The cause of the failure decides the exit code, not ExitPartial
+	if code := ExitCode(err); code != ExitServer {
+		t.Errorf("ExitCode() = %d, want %d", code, ExitServer)
+	}
+}
+
+func TestRunStagesInterrupted(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &recorder{}
+	first := r.task("first", "first", nil)
+	do := first.Do
+	first.Do = func(ctx context.Context) (nanohub.Change, error) {
+		defer cancel()
+		return do(ctx)
+	}
+	stages := [][]Task{{first}, {r.task("second", "second", nil)}}
+	results, err := testBulk().RunStages(ctx, stages)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("RunStages() error = %v, want context.Canceled", err)
+	}
+	if got := outcomes(results)["second"]; got != OutcomeNotApplied {
+		t.Errorf("second outcome = %s, want %s", got, OutcomeNotApplied)
+	}
+}
+
+func TestBulkReportsProblemsOnErrOut(t *testing.T) {
+	r := &recorder{}
+	var out, errOut strings.Builder
+	b := &Bulk{Op: "test", Concurrency: 1, out: &out, errOut: &errOut}
+	stages := [][]Task{
+		{r.task("put asset", "asset", errors.New("rejected")), r.task("put other", "other", nil)},
+		{r.task("put config", "config", nil, "asset")},
+	}
+	b.RunStages(context.Background(), stages)
+
+	for _, line := range []string{"Error: put asset: rejected", "Not applied: put config: needs asset"} {
+		if !strings.Contains(errOut.String(), line) {
+			t.Errorf("stderr = %q, want it to contain %q", errOut.String(), line)
+		}
+		if strings.Contains(out.String(), line) {
+			t.Errorf("stdout = %q, want it without %q", out.String(), line)
+		}
+	}
+	if !strings.Contains(out.String(), "test: ") {
+		t.Errorf("stdout = %q, want the summary", out.String())
+	}
+}
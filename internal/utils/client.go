@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/logger"
+	"github.com/spf13/viper"
+
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// NewClient returns a NanoHUB client configured from the current viper settings
+func NewClient(ctx context.Context) (*nanohub.Client, error) {
+	apiKey, err := APIKey(ctx)
+	if err != nil {
+		return nil, err
+	}
+	httpClient, err := HTTPClient()
+	if err != nil {
+		return nil, err
+	}
+
+	retry := nanohub.DefaultRetryPolicy
+	retry.MaxRetries = viper.GetInt("retries")
+	retry.MaxWait = viper.GetDuration("retry_max_wait")
+
+	return nanohub.NewClient(nanohub.Config{
+		URL:        viper.GetString("url"),
+		APIUser:    viper.GetString("api_user"),
+		APIKey:     apiKey,
+		HTTPClient: httpClient,
+		Retry:      &retry,
+		OnRetry: func(e nanohub.RetryEvent) {
+			logger.V(1).Infof("%s %s: %s, retry %d in %s", e.Method, e.URL, e.Reason, e.Attempt, e.Wait.Round(time.Millisecond))
+		},
+	})
+}
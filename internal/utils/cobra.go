@@ -29,22 +29,27 @@ func ApplyPreExecFn(cmd *cobra.Command, args []string) error {
 		return errors.New("failed to bind id to viper")
 	}
 
-	// For DDM commands, check UUID validity (skip for certain commands)
+	// For DDM commands, check UUID validity (skip for commands that do not
+	// act on a device)
 	if cmd.Parent() != nil && cmd.Parent().Name() == "ddm" {
-		if !(cmd.Name() == "declarations" || cmd.Name() == "declaration" || cmd.Parent().Name() == "declaration") {
+		if !(cmd.Name() == "declarations" || cmd.Name() == "declaration" || cmd.Name() == "export" || cmd.Parent().Name() == "declaration") {
 			clientUUID := viper.GetString("client_id")
 			if !validUUID(clientUUID) {
-				return errors.New("Invalid UUID provided")
+				return NewUsageError("Invalid UUID provided")
 			}
 		}
 	}
 
 	// Make sure mandatory values are present before continuing
 	if viper.GetString("url") == "" {
-		return errors.New("Base URL must be provided!")
+		return NewUsageError("Base URL must be provided!")
 	}
-	if viper.GetString("api_key") == "" {
-		return errors.New("API Key must be provided!")
+	apiKey, err := APIKey(cmd.Context())
+	if err != nil {
+		return err
+	}
+	if apiKey == "" {
+		return NewUsageError("API Key must be provided!")
 	}
 
 	return nil
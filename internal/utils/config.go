@@ -0,0 +1,276 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"gopkg.in/yaml.v3"
+)
+
+// ConfigFile is the on-disk nanohubctl configuration. Settings apply to every
+// profile, the active profile's settings override them.
+//
+//	profile: staging
+//	profiles:
+//	  staging:
+//	    url: https://staging.nanohub.example.com/
+//	    api_key_command: op read op://Private/nanohub-staging/credential
+//	retries: 5
+type ConfigFile struct {
+	// Profile is used when neither --profile nor NANOHUB_PROFILE are set
+	Profile  string             `yaml:"profile,omitempty"`
+	Profiles map[string]Profile `yaml:"profiles,omitempty"`
+	Settings map[string]any     `yaml:",inline"`
+}
+
+// Profile holds the settings of one NanoHUB instance: url, api_user, api_key
+// or another credential source, client_id and any global setting
+type Profile map[string]any
+
+// Settings are the keys nanohubctl reads from flags, NANOHUB_* env vars and
+// the config file
+var Settings = []string{
+	"profile",
+	"url",
+	"api_user",
+	"api_key",
+	"api_key_file",
+	"api_key_command",
+	"client_id",
+	"concurrency",
+	"timeout",
+	"retries",
+	"retry_max_wait",
+	"ca_bundle",
+	"client_cert",
+	"client_key",
+	"https_proxy",
+	"insecure_skip_verify",
+	"output",
+	"debug",
+}
+
+// SecretSettings are never shown in full
+var SecretSettings = []string{"api_key"}
+
+// ConfigDir returns the directory nanohubctl keeps its state in
+func ConfigDir() (string, error) {
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get home directory: %v", err)
+	}
+	return filepath.Join(homeDir, ".nanohubctl"), nil
+}
+
+// ConfigPath returns the path of the config file, --config if set
+func ConfigPath() (string, error) {
+	if path := viper.GetString("config"); path != "" {
+		return path, nil
+	}
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.yaml"), nil
+}
+
+// ReadConfigFile parses the config file. A missing file is an empty config.
+func ReadConfigFile() (*ConfigFile, error) {
+	path, err := ConfigPath()
+	if err != nil {
+		return nil, err
+	}
+	cf := &ConfigFile{}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return cf, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config: %w", err)
+	}
+	if err := yaml.Unmarshal(data, cf); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return cf, nil
+}
+
+// WriteConfigFile saves cf to the config file
+func WriteConfigFile(cf *ConfigFile) error {
+	path, err := ConfigPath()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(cf); err != nil {
+		return fmt.Errorf("failed to marshal config: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	// The file may hold API keys
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		return fmt.Errorf("failed to write config file: %v", err)
+	}
+	return nil
+}
+
+// ProfileNames returns the sorted names of profiles
+func ProfileNames(profiles map[string]Profile) []string {
+	names := make([]string, 0, len(profiles))
+	for name := range profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Layers of settings, in increasing order of precedence
+const (
+	layerNone = iota
+	layerGlobal
+	layerProfile
+	layerEnv
+	layerFlag
+)
+
+// layers records what LoadConfig read, so settingLayer can tell which layer
+// set a setting
+var layers struct {
+	flags   *pflag.FlagSet
+	global  map[string]any
+	profile Profile
+}
+
+// settingLayer returns the layer of highest precedence that sets key
+func settingLayer(key string) int {
+	isSet := func(v any, ok bool) bool {
+		return ok && v != nil && fmt.Sprint(v) != ""
+	}
+	switch {
+	case layers.flags != nil && layers.flags.Lookup(key) != nil && layers.flags.Changed(key):
+		return layerFlag
+	}
+	if _, ok := SettingEnv(key); ok {
+		return layerEnv
+	}
+	if v, ok := layers.profile[key]; isSet(v, ok) {
+		return layerProfile
+	}
+	if v, ok := layers.global[key]; isSet(v, ok) {
+		return layerGlobal
+	}
+	return layerNone
+}
+
+// SettingEnv returns the NANOHUB_* env var for key and whether it sets key.
+// Like viper, an empty variable does not count.
+func SettingEnv(key string) (string, bool) {
+	env := "NANOHUB_" + strings.ToUpper(key)
+	return env, os.Getenv(env) != ""
+}
+
+// layerName describes a layer in error messages
+func layerName(layer int) string {
+	switch layer {
+	case layerFlag:
+		return "flags"
+	case layerEnv:
+		return "NANOHUB_* env vars"
+	case layerProfile:
+		return fmt.Sprintf("profile %q", ActiveProfile())
+	}
+	return "the config file"
+}
+
+// LoadConfig layers the config file into viper below flags and env vars:
+// flags, then NANOHUB_* env vars, then the active profile, then the file's
+// global settings, then defaults. Instances provisioned with `new` are
+// available as profiles too. flags are the command's flags, to tell settings
+// given on the command line from the other layers.
+func LoadConfig(flags *pflag.FlagSet) error {
+	layers.flags = flags
+	cf, err := ReadConfigFile()
+	if err != nil {
+		return err
+	}
+	instances, err := ReadInstances()
+	if err != nil {
+		return err
+	}
+	settings := map[string]any{}
+	for k, v := range cf.Settings {
+		settings[k] = v
+	}
+	layers.global = settings
+	if cf.Profile != "" {
+		settings["profile"] = cf.Profile
+	}
+	if err := viper.MergeConfigMap(settings); err != nil {
+		return err
+	}
+
+	profiles := AllProfiles(cf, instances)
+	name := ActiveProfile()
+	if name == "" && len(instances) == 1 && viper.GetString("url") == "" {
+		// Nothing else configured, so use what `new` provisioned
+		name = instances[0].ProfileName()
+		viper.Set("profile", name)
+	}
+	if name == "" {
+		return nil
+	}
+	profile, ok := profiles[name]
+	if !ok {
+		path, _ := ConfigPath()
+		return &UsageError{Err: &ProfileNotFoundError{Name: name, Path: path}}
+	}
+	layers.profile = profile
+	return viper.MergeConfigMap(profile)
+}
+
+// ProfileNotFoundError is returned by LoadConfig when the active profile does
+// not exist
+type ProfileNotFoundError struct {
+	Name string
+	Path string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf("profile %q not found in %s", e.Name, e.Path)
+}
+
+// AllProfiles returns the profiles in cf plus one per provisioned instance.
+// Settings of a config file profile win over those of the instance of the
+// same name, so an instance can be given values or a client_id.
+func AllProfiles(cf *ConfigFile, instances []Instance) map[string]Profile {
+	profiles := map[string]Profile{}
+	for _, instance := range instances {
+		profiles[instance.ProfileName()] = instance.AsProfile()
+	}
+	for name, profile := range cf.Profiles {
+		merged := Profile{}
+		for k, v := range profiles[name] {
+			merged[k] = v
+		}
+		for k, v := range profile {
+			merged[k] = v
+		}
+		profiles[name] = merged
+	}
+	return profiles
+}
+
+// ActiveProfile returns the name of the profile in use, if any
+func ActiveProfile() string {
+	return viper.GetString("profile")
+}
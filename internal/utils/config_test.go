@@ -0,0 +1,216 @@
+package utils
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// testSettings are bound to flags and env vars by setupConfig
+var testSettings = []string{"profile", "url", "api_key", "api_key_file", "api_key_command", "retries"}
+
+// setupConfig binds flags and NANOHUB_* env vars to viper the way the root
+// command does, with HOME in a temporary directory and config as the config
+// file. It returns the command whose flags to set.
+func setupConfig(t *testing.T, config string) *cobra.Command {
+	t.Helper()
+	viper.Reset()
+	t.Cleanup(viper.Reset)
+	t.Cleanup(func() { layers.flags, layers.global, layers.profile = nil, nil, nil })
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+
+	cmd := &cobra.Command{}
+	viper.SetEnvPrefix("NANOHUB")
+	for _, key := range testSettings {
+		cmd.Flags().String(key, "", "")
+		viper.BindPFlag(key, cmd.Flags().Lookup(key))
+		viper.BindEnv(strings.ToUpper(key))
+		t.Setenv("NANOHUB_"+strings.ToUpper(key), "")
+	}
+
+	path := filepath.Join(home, "config.yaml")
+	if err := os.WriteFile(path, []byte(config), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	viper.Set("config", path)
+	return cmd
+}
+
+const testConfig = `
+url: https://global.example.com/
+retries: 5
+profile: staging
+profiles:
+  staging:
+    url: https://staging.example.com/
+  prod:
+    url: https://prod.example.com/
+    retries: 9
+`
+
+func TestLoadConfig(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		env    map[string]string
+		flags  map[string]string
+		want   map[string]string
+	}{
+		{
+			name:   "global settings",
+			config: "url: https://global.example.com/\nretries: 5\n",
+			want:   map[string]string{"url": "https://global.example.com/", "retries": "5", "profile": ""},
+		},
+		{
+			name:   "default profile over global settings",
+			config: testConfig,
+			want:   map[string]string{"url": "https://staging.example.com/", "retries": "5", "profile": "staging"},
+		},
+		{
+			name:   "profile from env",
+			config: testConfig,
+			env:    map[string]string{"profile": "prod"},
+			want:   map[string]string{"url": "https://prod.example.com/", "retries": "9", "profile": "prod"},
+		},
+		{
+			name:   "profile from flag",
+			config: testConfig,
+			env:    map[string]string{"profile": "staging"},
+			flags:  map[string]string{"profile": "prod"},
+			want:   map[string]string{"url": "https://prod.example.com/", "profile": "prod"},
+		},
+		{
+			name:   "env over profile",
+			config: testConfig,
+			env:    map[string]string{"url": "https://env.example.com/"},
+			want:   map[string]string{"url": "https://env.example.com/"},
+		},
+		{
+			name:   "flag over env",
+			config: testConfig,
+			env:    map[string]string{"url": "https://env.example.com/"},
+			flags:  map[string]string{"url": "https://flag.example.com/"},
+			want:   map[string]string{"url": "https://flag.example.com/"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := setupConfig(t, tt.config)
+			for k, v := range tt.env {
+				t.Setenv("NANOHUB_"+strings.ToUpper(k), v)
+			}
+			for k, v := range tt.flags {
+				cmd.Flags().Set(k, v)
+			}
+			if err := LoadConfig(cmd.Flags()); err != nil {
+				t.Fatalf("LoadConfig() error = %v", err)
+			}
+			for k, want := range tt.want {
+				if got := viper.GetString(k); got != want {
+					t.Errorf("%s = %q, want %q", k, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestLoadConfigInstances(t *testing.T) {
+	writeInstances := func(t *testing.T, instances ...Instance) {
+		t.Helper()
+		if err := WriteInstances(instances); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Run("lone instance", func(t *testing.T) {
+		cmd := setupConfig(t, "")
+		writeInstances(t, Instance{Name: "abc.nanohub.example.com", APIKey: "key"})
+		if err := LoadConfig(cmd.Flags()); err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		if got := viper.GetString("url"); got != "https://abc.nanohub.example.com" {
+			t.Errorf("url = %q, want the instance URL", got)
+		}
+	})
+
+	t.Run("config file profile wins", func(t *testing.T) {
+		cmd := setupConfig(t, "profiles:\n  lab:\n    url: https://lab.example.com/\n")
+		writeInstances(t,
+			Instance{Profile: "lab", Name: "lab.nanohub.example.com", APIKey: "key"},
+			Instance{Name: "other.nanohub.example.com", APIKey: "key"},
+		)
+		cmd.Flags().Set("profile", "lab")
+		if err := LoadConfig(cmd.Flags()); err != nil {
+			t.Fatalf("LoadConfig() error = %v", err)
+		}
+		if got := viper.GetString("url"); got != "https://lab.example.com/" {
+			t.Errorf("url = %q, want the config file's", got)
+		}
+		// Settings the config file does not have come from the instance
+		if got := viper.GetString("api_key"); got != "key" {
+			t.Errorf("api_key = %q, want the instance's", got)
+		}
+	})
+}
+
+func TestLoadConfigMissingProfile(t *testing.T) {
+	cmd := setupConfig(t, testConfig)
+	cmd.Flags().Set("profile", "gone")
+	err := LoadConfig(cmd.Flags())
+	var notFound *ProfileNotFoundError
+	if !errors.As(err, &notFound) || notFound.Name != "gone" {
+		t.Fatalf("LoadConfig() error = %v, want a ProfileNotFoundError", err)
+	}
+	if code := ExitCode(err); code != ExitUsage {
+		t.Errorf("ExitCode() = %d, want %d", code, ExitUsage)
+	}
+}
+
+func TestSettingLayer(t *testing.T) {
+	cmd := setupConfig(t, testConfig)
+	t.Setenv("NANOHUB_API_KEY", "env-key")
+	// An empty env var does not hide the profile's setting
+	t.Setenv("NANOHUB_URL", "")
+	cmd.Flags().Set("api_key_file", "key.txt")
+	if err := LoadConfig(cmd.Flags()); err != nil {
+		t.Fatal(err)
+	}
+	for key, want := range map[string]int{
+		"api_key_file":    layerFlag,
+		"api_key":         layerEnv,
+		"url":             layerProfile,
+		"retries":         layerGlobal,
+		"api_key_command": layerNone,
+	} {
+		if got := settingLayer(key); got != want {
+			t.Errorf("settingLayer(%s) = %d, want %d", key, got, want)
+		}
+	}
+}
+
+func TestAllProfiles(t *testing.T) {
+	cf := &ConfigFile{Profiles: map[string]Profile{
+		"lab":     {"client_id": "abc", "values": map[string]any{"env": "lab"}},
+		"staging": {"url": "https://staging.example.com/"},
+	}}
+	instances := []Instance{{Profile: "lab", Name: "lab.nanohub.example.com", APIKey: "key"}}
+	want := map[string]Profile{
+		"lab": {
+			"url":       "https://lab.nanohub.example.com",
+			"api_key":   "key",
+			"client_id": "abc",
+			"values":    map[string]any{"env": "lab"},
+		},
+		"staging": {"url": "https://staging.example.com/"},
+	}
+	if got := AllProfiles(cf, instances); !reflect.DeepEqual(got, want) {
+		t.Errorf("AllProfiles() = %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"github.com/google/logger"
+	"github.com/spf13/viper"
+)
+
+var apiKeyCache struct {
+	sync.Mutex
+	key string
+}
+
+// apiKeySources are the settings the API key can be taken from
+var apiKeySources = []string{"api_key", "api_key_file", "api_key_command"}
+
+// APIKey returns the API key from whichever of api_key, api_key_file and
+// api_key_command is set in the layer of highest precedence, so a flag wins
+// over an env var or profile. Two of them set in the same layer is a usage
+// error. The result is cached so helpers only run once.
+func APIKey(ctx context.Context) (string, error) {
+	apiKeyCache.Lock()
+	defer apiKeyCache.Unlock()
+	if apiKeyCache.key != "" {
+		return apiKeyCache.key, nil
+	}
+
+	source, err := apiKeySource()
+	if err != nil {
+		return "", err
+	}
+	var key string
+	switch source {
+	case "api_key":
+		key = viper.GetString("api_key")
+	case "api_key_file":
+		key, err = apiKeyFromFile(viper.GetString("api_key_file"))
+	case "api_key_command":
+		key, err = apiKeyFromCommand(ctx, viper.GetString("api_key_command"))
+	}
+	if err != nil {
+		return "", err
+	}
+	apiKeyCache.key = key
+	return key, nil
+}
+
+// apiKeySource returns the credential setting set in the layer of highest
+// precedence, empty if none is set
+func apiKeySource() (string, error) {
+	var sources []string
+	best := layerNone
+	for _, source := range apiKeySources {
+		switch layer := settingLayer(source); {
+		case layer == layerNone || layer < best:
+		case layer > best:
+			best, sources = layer, []string{source}
+		default:
+			sources = append(sources, source)
+		}
+	}
+	if len(sources) > 1 {
+		return "", NewUsageError("%s are set in %s, set only one", strings.Join(sources, " and "), layerName(best))
+	}
+	if len(sources) == 0 {
+		return "", nil
+	}
+	return sources[0], nil
+}
+
+// apiKeyFromFile reads the key from path, or from stdin when path is "-"
+func apiKeyFromFile(path string) (string, error) {
+	var data []byte
+	var err error
+	if path == "-" {
+		data, err = io.ReadAll(os.Stdin)
+	} else {
+		path = ExpandHome(path)
+		if info, statErr := os.Stat(path); statErr == nil && runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+			logger.Warningf("API key file %s is readable by other users, consider chmod 600", path)
+		}
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to read API key file: %w", err)
+	}
+	return firstLine(data)
+}
+
+// apiKeyFromCommand runs command through the shell and reads the key from its
+// stdout, the same way git runs credential helpers
+func apiKeyFromCommand(ctx context.Context, command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	// Password managers may need to prompt to unlock
+	cmd.Stdin = os.Stdin
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("api_key_command failed: %w", err)
+	}
+	key, err := firstLine(stdout.Bytes())
+	if err != nil {
+		return "", fmt.Errorf("api_key_command: %w", err)
+	}
+	return key, nil
+}
+
+func firstLine(data []byte) (string, error) {
+	line, _, _ := strings.Cut(strings.TrimSpace(string(data)), "\n")
+	line = strings.TrimSpace(line)
+	if line == "" {
+		return "", errors.New("no API key found")
+	}
+	return line, nil
+}
+
+// ExpandHome replaces a leading ~/ in path with the user's home directory
+func ExpandHome(path string) string {
+	rest, ok := strings.CutPrefix(path, "~/")
+	if !ok {
+		return path
+	}
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return path
+	}
+	return filepath.Join(homeDir, rest)
+}
@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeKeyFile writes an API key file and returns its path
+func writeKeyFile(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "key")
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func resetAPIKeyCache(t *testing.T) {
+	apiKeyCache.key = ""
+	t.Cleanup(func() { apiKeyCache.key = "" })
+}
+
+func TestAPIKey(t *testing.T) {
+	keyFile := writeKeyFile(t, "\n  file-key  \nsecond line\n")
+	tests := []struct {
+		name   string
+		config string
+		env    map[string]string
+		flags  map[string]string
+		want   string
+	}{
+		{
+			name:   "api_key",
+			config: "api_key: config-key\n",
+			want:   "config-key",
+		},
+		{
+			name:  "first line of api_key_file",
+			flags: map[string]string{"api_key_file": keyFile},
+			want:  "file-key",
+		},
+		{
+			name: "api_key_command",
+			env:  map[string]string{"api_key_command": "echo command-key"},
+			want: "command-key",
+		},
+		{
+			name:  "flag over env",
+			env:   map[string]string{"api_key": "env-key"},
+			flags: map[string]string{"api_key_command": "echo command-key"},
+			want:  "command-key",
+		},
+		{
+			name:   "env over profile",
+			config: "profile: p\nprofiles:\n  p:\n    api_key: profile-key\n",
+			env:    map[string]string{"api_key_file": keyFile},
+			want:   "file-key",
+		},
+		{
+			name:   "profile over global settings",
+			config: "api_key: global-key\nprofile: p\nprofiles:\n  p:\n    api_key_command: echo command-key\n",
+			want:   "command-key",
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := setupConfig(t, tt.config)
+			resetAPIKeyCache(t)
+			for k, v := range tt.env {
+				t.Setenv("NANOHUB_"+strings.ToUpper(k), v)
+			}
+			for k, v := range tt.flags {
+				cmd.Flags().Set(k, v)
+			}
+			if err := LoadConfig(cmd.Flags()); err != nil {
+				t.Fatal(err)
+			}
+			got, err := APIKey(context.Background())
+			if err != nil {
+				t.Fatalf("APIKey() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("APIKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyConflict(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		flags  map[string]string
+		want   string
+	}{
+		{
+			name:  "flags",
+			flags: map[string]string{"api_key": "a", "api_key_file": "b"},
+			want:  "api_key and api_key_file are set in flags, set only one",
+		},
+		{
+			name:   "profile",
+			config: "profile: p\nprofiles:\n  p:\n    api_key_file: a\n    api_key_command: b\n",
+			want:   `api_key_file and api_key_command are set in profile "p", set only one`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := setupConfig(t, tt.config)
+			resetAPIKeyCache(t)
+			for k, v := range tt.flags {
+				cmd.Flags().Set(k, v)
+			}
+			if err := LoadConfig(cmd.Flags()); err != nil {
+				t.Fatal(err)
+			}
+			_, err := APIKey(context.Background())
+			var usageErr *UsageError
+			if !errors.As(err, &usageErr) || err.Error() != tt.want {
+				t.Errorf("APIKey() error = %v, want usage error %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestAPIKeyErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+		val  string
+		want string
+	}{
+		{"missing file", "api_key_file", filepath.Join(t.TempDir(), "missing"), "failed to read API key file"},
+		{"empty file", "api_key_file", writeKeyFile(t, "\n\n"), "no API key found"},
+		{"failing command", "api_key_command", "exit 3", "api_key_command failed"},
+		{"command without output", "api_key_command", "true", "api_key_command: no API key found"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cmd := setupConfig(t, "")
+			resetAPIKeyCache(t)
+			cmd.Flags().Set(tt.key, tt.val)
+			if err := LoadConfig(cmd.Flags()); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := APIKey(context.Background()); err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("APIKey() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestExpandHome(t *testing.T) {
+	t.Setenv("HOME", "/home/admin")
+	for path, want := range map[string]string{
+		"~/key":     "/home/admin/key",
+		"/etc/key":  "/etc/key",
+		"~other/k":  "~other/k",
+		"relative~": "relative~",
+	} {
+		if got := ExpandHome(path); got != want {
+			t.Errorf("ExpandHome(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+// Exit codes returned by nanohubctl so scripts can branch on the kind of failure
+const (
+	ExitOK       = 0
+	ExitError    = 1 // Any failure not covered below
+	ExitUsage    = 2 // Bad flags, arguments or missing settings
+	ExitAuth     = 3 // The server rejected the credentials (401/403)
+	ExitNotFound = 4 // The server returned 404
+	ExitConflict = 5 // The server returned 409, or the change conflicts with its state
+	ExitPartial  = 6 // Some items of a bulk operation failed
+	ExitServer   = 7 // The server returned a 5xx error
+
+	ExitTimeout     = 124 // The --timeout expired
+	ExitInterrupted = 130 // Interrupted by Ctrl-C or SIGTERM
+)
+
+// ErrDifferences is returned by commands that compare things, such as
+// declaration diff, when they found differences. Like diff(1) it exits with
+// ExitError and no error message.
+var ErrDifferences = errors.New("differences found")
+
+// UsageError marks an error caused by how nanohubctl was invoked
+type UsageError struct {
+	Err error
+}
+
+func (e *UsageError) Error() string { return e.Err.Error() }
+func (e *UsageError) Unwrap() error { return e.Err }
+
+// NewUsageError returns a UsageError with a formatted message
+func NewUsageError(format string, a ...any) error {
+	return &UsageError{Err: fmt.Errorf(format, a...)}
+}
+
+// ConflictError marks a change refused because it conflicts with the state
+// of the server, such as deleting a declaration that is still in a set
+type ConflictError struct {
+	Err error
+}
+
+func (e *ConflictError) Error() string { return e.Err.Error() }
+func (e *ConflictError) Unwrap() error { return e.Err }
+
+// NewConflictError returns a ConflictError with a formatted message
+func NewConflictError(format string, a ...any) error {
+	return &ConflictError{Err: fmt.Errorf(format, a...)}
+}
+
+// PartialError reports that items of a bulk operation failed, or were not
+// applied because an item they need failed
+type PartialError struct {
+	Op         string
+	Failed     int
+	NotApplied int
+	Total      int
+	// Errs are the errors of the failed items
+	Errs []error
+}
+
+func (e *PartialError) Error() string {
+	msg := fmt.Sprintf("%s: %d of %d failed", e.Op, e.Failed, e.Total)
+	if e.NotApplied > 0 {
+		msg += fmt.Sprintf(", %d not applied", e.NotApplied)
+	}
+	return msg
+}
+
+func (e *PartialError) Unwrap() []error { return e.Errs }
+
+// Applied reports whether any item succeeded
+func (e *PartialError) Applied() bool {
+	return e.Failed+e.NotApplied < e.Total
+}
+
+// ExitCode maps an error returned by a command to the process exit code
+func ExitCode(err error) int {
+	if err == nil {
+		return ExitOK
+	}
+	if errors.Is(err, context.Canceled) {
+		return ExitInterrupted
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ExitTimeout
+	}
+	var usageErr *UsageError
+	if errors.As(err, &usageErr) {
+		return ExitUsage
+	}
+	var conflictErr *ConflictError
+	if errors.As(err, &conflictErr) {
+		return ExitConflict
+	}
+	// When nothing was applied the exit code reflects why the items failed
+	var partialErr *PartialError
+	if errors.As(err, &partialErr) && partialErr.Applied() {
+		return ExitPartial
+	}
+	switch status := nanohub.StatusCode(err); {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ExitAuth
+	case status == http.StatusNotFound:
+		return ExitNotFound
+	case status == http.StatusConflict:
+		return ExitConflict
+	case status >= 500:
+		return ExitServer
+	}
+	return ExitError
+}
+
+// BulkError summarises the failures of a bulk operation over total items,
+// notApplied of which were skipped because an item they need failed. A lone
+// item's error is returned as is so the exit code reflects its cause.
+func BulkError(op string, failed []error, notApplied, total int) error {
+	switch {
+	case len(failed) == 0 && notApplied == 0:
+		return nil
+	case total == 1 && len(failed) == 1:
+		return failed[0]
+	}
+	return &PartialError{Op: op, Failed: len(failed), NotApplied: notApplied, Total: total, Errs: failed}
+}
@@ -0,0 +1,41 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/macadmins/nanohubctl/pkg/nanohub"
+)
+
+func TestExitCode(t *testing.T) {
+	apiErr := func(status int) error {
+		return &nanohub.APIError{StatusCode: status, Method: http.MethodGet, Endpoint: "/api/v1/ddm/declarations"}
+	}
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", want: ExitOK},
+		{name: "plain error", err: errors.New("boom"), want: ExitError},
+		{name: "usage", err: NewUsageError("bad flag"), want: ExitUsage},
+		{name: "conflict", err: NewConflictError("still in sets"), want: ExitConflict},
+		{name: "wrapped conflict", err: fmt.Errorf("delete: %w", NewConflictError("still in sets")), want: ExitConflict},
+		{name: "401", err: apiErr(http.StatusUnauthorized), want: ExitAuth},
+		{name: "404", err: apiErr(http.StatusNotFound), want: ExitNotFound},
+		{name: "409", err: apiErr(http.StatusConflict), want: ExitConflict},
+		{name: "502", err: apiErr(http.StatusBadGateway), want: ExitServer},
+		{name: "timeout", err: context.DeadlineExceeded, want: ExitTimeout},
+		{name: "interrupted", err: context.Canceled, want: ExitInterrupted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ExitCode(tt.err); got != tt.want {
+				t.Errorf("ExitCode(%v) = %d, want %d", tt.err, got, tt.want)
+			}
+		})
+	}
+}
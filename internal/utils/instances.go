@@ -0,0 +1,91 @@
+package utils
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Instance is a NanoHUB instance provisioned with `nanohubctl new`
+type Instance struct {
+	// Profile is the profile name the instance is available as, Name if empty
+	Profile           string `json:"profile,omitempty"`
+	Name              string `json:"name"`
+	EnrollmentProfile string `json:"enrollment_profile"`
+	APIKey            string `json:"api_key"`
+}
+
+// ProfileName returns the name of the profile the instance is available as
+func (i Instance) ProfileName() string {
+	if i.Profile != "" {
+		return i.Profile
+	}
+	return i.Name
+}
+
+// URL returns the base URL of the instance
+func (i Instance) URL() string {
+	return "https://" + i.Name
+}
+
+// AsProfile returns the profile settings for the instance
+func (i Instance) AsProfile() Profile {
+	return Profile{"url": i.URL(), "api_key": i.APIKey}
+}
+
+// InstancesPath returns the file provisioned instances are stored in
+func InstancesPath() (string, error) {
+	dir, err := ConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "config.json"), nil
+}
+
+// ReadInstances returns the provisioned instances. Older releases stored a
+// single instance object rather than a list, both are accepted.
+func ReadInstances() ([]Instance, error) {
+	path, err := InstancesPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read instances: %v", err)
+	}
+
+	var instances []Instance
+	if err := json.Unmarshal(data, &instances); err == nil {
+		return instances, nil
+	}
+	var instance Instance
+	if err := json.Unmarshal(data, &instance); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return []Instance{instance}, nil
+}
+
+// WriteInstances saves the provisioned instances
+func WriteInstances(instances []Instance) error {
+	path, err := InstancesPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create config directory: %v", err)
+	}
+	data, err := json.MarshalIndent(instances, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal instances: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return fmt.Errorf("failed to write instances: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// debugBodyLimit is the number of body bytes dumped per request or response
+const debugBodyLimit = 4096
+
+const redacted = "[REDACTED]"
+
+// apiKeyJSON matches the api_key field of provisioning responses
+var apiKeyJSON = regexp.MustCompile(`("api_key"\s*:\s*)"[^"]*"`)
+
+// traceTransport dumps every request and response to w with credentials redacted
+type traceTransport struct {
+	next    http.RoundTripper
+	w       io.Writer
+	secrets []string
+
+	mu sync.Mutex
+}
+
+func newTraceTransport(next http.RoundTripper, w io.Writer, secrets ...string) *traceTransport {
+	t := &traceTransport{next: next, w: w}
+	for _, s := range secrets {
+		if s != "" {
+			t.secrets = append(t.secrets, s)
+		}
+	}
+	return t
+}
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var reqBody []byte
+	if req.Body != nil && req.Body != http.NoBody {
+		var err error
+		reqBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+
+	var buf strings.Builder
+	fmt.Fprintf(&buf, "> %s %s\n", req.Method, t.redact(req.URL.String()))
+	t.writeHeaders(&buf, ">", req.Header)
+	t.writeBody(&buf, ">", reqBody, len(reqBody))
+	if err != nil {
+		fmt.Fprintf(&buf, "< error after %s: %s\n\n", elapsed, t.redact(err.Error()))
+		t.flush(buf.String())
+		return nil, err
+	}
+
+	// Only buffer what gets dumped, the rest of the body streams through untouched.
+	respBody, readErr := io.ReadAll(io.LimitReader(resp.Body, debugBodyLimit+1))
+	resp.Body = struct {
+		io.Reader
+		io.Closer
+	}{io.MultiReader(bytes.NewReader(respBody), resp.Body), resp.Body}
+
+	fmt.Fprintf(&buf, "< %s %s (%s)\n", resp.Proto, resp.Status, elapsed)
+	t.writeHeaders(&buf, "<", resp.Header)
+	size := len(respBody)
+	if resp.ContentLength > int64(size) {
+		size = int(resp.ContentLength)
+	}
+	t.writeBody(&buf, "<", respBody, size)
+	if readErr != nil {
+		fmt.Fprintf(&buf, "< error reading body: %s\n", readErr)
+	}
+	buf.WriteString("\n")
+	t.flush(buf.String())
+	return resp, nil
+}
+
+func (t *traceTransport) writeHeaders(buf *strings.Builder, prefix string, header http.Header) {
+	keys := make([]string, 0, len(header))
+	for k := range header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		for _, v := range header[k] {
+			fmt.Fprintf(buf, "%s %s: %s\n", prefix, k, t.redactHeader(k, v))
+		}
+	}
+}
+
+func (t *traceTransport) writeBody(buf *strings.Builder, prefix string, body []byte, size int) {
+	if len(body) == 0 {
+		return
+	}
+	truncated := len(body) > debugBodyLimit
+	if truncated {
+		body = body[:debugBodyLimit]
+	}
+	fmt.Fprintf(buf, "%s\n", prefix)
+	for _, line := range strings.Split(strings.TrimRight(t.redact(string(body)), "\n"), "\n") {
+		fmt.Fprintf(buf, "%s %s\n", prefix, line)
+	}
+	if truncated {
+		fmt.Fprintf(buf, "%s [truncated, %d of at least %d bytes shown]\n", prefix, debugBodyLimit, size)
+	}
+}
+
+func (t *traceTransport) redactHeader(key, value string) string {
+	switch http.CanonicalHeaderKey(key) {
+	case "Authorization", "Proxy-Authorization":
+		// Keep the auth scheme, it helps when debugging the wrong kind of credentials
+		if scheme, _, ok := strings.Cut(value, " "); ok {
+			return scheme + " " + redacted
+		}
+		return redacted
+	case "Cookie", "Set-Cookie":
+		return redacted
+	}
+	return t.redact(value)
+}
+
+func (t *traceTransport) redact(s string) string {
+	s = apiKeyJSON.ReplaceAllString(s, `$1"`+redacted+`"`)
+	for _, secret := range t.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	return s
+}
+
+func (t *traceTransport) flush(s string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	io.WriteString(t.w, s)
+}
@@ -0,0 +1,121 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const testSecret = "s3cr3t-key"
+
+func TestTraceRedact(t *testing.T) {
+	tr := newTraceTransport(nil, io.Discard, testSecret, "")
+	if len(tr.secrets) != 1 {
+		t.Fatalf("secrets = %q, want empty secrets dropped", tr.secrets)
+	}
+
+	tests := []struct {
+		name string
+		in   string
+		want string
+	}{
+		{
+			name: "api_key field",
+			in:   `{"name":"dev","api_key": "abc123"}`,
+			want: `{"name":"dev","api_key": "[REDACTED]"}`,
+		},
+		{
+			name: "secret",
+			in:   "https://nanohub.example.com/?key=" + testSecret,
+			want: "https://nanohub.example.com/?key=[REDACTED]",
+		},
+		{
+			name: "every occurrence",
+			in:   testSecret + " " + testSecret,
+			want: "[REDACTED] [REDACTED]",
+		},
+		{
+			name: "nothing to redact",
+			in:   `{"api_keys":"x"}`,
+			want: `{"api_keys":"x"}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tr.redact(tt.in); got != tt.want {
+				t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceRedactHeader(t *testing.T) {
+	tr := newTraceTransport(nil, io.Discard, testSecret)
+	tests := []struct {
+		key   string
+		value string
+		want  string
+	}{
+		{"Authorization", "Basic bmFub2h1Yjpz", "Basic [REDACTED]"},
+		{"Authorization", "Bearer eyJhbGciOi", "Bearer [REDACTED]"},
+		{"authorization", "Bearer eyJhbGciOi", "Bearer [REDACTED]"},
+		{"Authorization", "bmFub2h1Yjpz", "[REDACTED]"},
+		{"Proxy-Authorization", "Basic dXNlcjpwYXNz", "Basic [REDACTED]"},
+		{"Cookie", "session=abc", "[REDACTED]"},
+		{"Set-Cookie", "session=abc; HttpOnly", "[REDACTED]"},
+		{"X-Api-Key", testSecret, "[REDACTED]"},
+		{"Content-Type", "application/json", "application/json"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			if got := tr.redactHeader(tt.key, tt.value); got != tt.want {
+				t.Errorf("redactHeader(%q, %q) = %q, want %q", tt.key, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTraceRoundTrip(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		io.WriteString(w, `{"api_key":"new-key","echo":"`+testSecret+`"}`)
+	}))
+	defer srv.Close()
+
+	var dump bytes.Buffer
+	client := &http.Client{Transport: newTraceTransport(http.DefaultTransport, &dump, testSecret)}
+	req, err := http.NewRequest(http.MethodPut, srv.URL+"/api/v1/ddm/declarations", strings.NewReader(`{"key":"`+testSecret+`"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.SetBasicAuth("nanohub", testSecret)
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The caller still gets the response untouched
+	if !strings.Contains(string(body), testSecret) {
+		t.Errorf("response body = %s, want it unredacted", body)
+	}
+	basic := base64.StdEncoding.EncodeToString([]byte("nanohub:" + testSecret))
+	for _, leak := range []string{testSecret, basic, "new-key", "session=abc"} {
+		if strings.Contains(dump.String(), leak) {
+			t.Errorf("dump contains %q:\n%s", leak, dump.String())
+		}
+	}
+	for _, want := range []string{"> PUT ", "> Authorization: Basic [REDACTED]", "< HTTP/1.1 200 OK"} {
+		if !strings.Contains(dump.String(), want) {
+			t.Errorf("dump does not contain %q:\n%s", want, dump.String())
+		}
+	}
+}
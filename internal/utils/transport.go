@@ -0,0 +1,76 @@
+package utils
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/google/logger"
+	"github.com/spf13/viper"
+)
+
+// HTTPClient returns an http.Client that applies the ca_bundle, client_cert,
+// client_key, https_proxy and insecure_skip_verify settings and keeps enough
+// idle connections for --concurrency requests. With debug set, every request
+// and response is also dumped to stderr.
+func HTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// Keep a connection per worker of bulk operations and fan outs open,
+	// instead of dialing again for most requests
+	transport.MaxIdleConnsPerHost = max(viper.GetInt("concurrency"), http.DefaultMaxIdleConnsPerHost)
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+
+	if caBundle := viper.GetString("ca_bundle"); caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", caBundle)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	certFile, keyFile := viper.GetString("client_cert"), viper.GetString("client_key")
+	if certFile != "" || keyFile != "" {
+		if certFile == "" || keyFile == "" {
+			return nil, errors.New("client_cert and client_key must be provided together")
+		}
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	if viper.GetBool("insecure_skip_verify") {
+		logger.Warning("TLS certificate verification is disabled, connections are NOT secure")
+		tlsConfig.InsecureSkipVerify = true
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	if httpsProxy := viper.GetString("https_proxy"); httpsProxy != "" {
+		proxyUrl, err := url.Parse(httpsProxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid https_proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxyUrl)
+	}
+
+	if viper.GetBool("debug") {
+		// Only redact a key that is already resolved, never run a helper just for this
+		apiKeyCache.Lock()
+		apiKey := apiKeyCache.key
+		apiKeyCache.Unlock()
+		return &http.Client{Transport: newTraceTransport(transport, os.Stderr, viper.GetString("api_key"), apiKey)}, nil
+	}
+	return &http.Client{Transport: transport}, nil
+}
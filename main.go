@@ -2,15 +2,24 @@ package main
 
 import (
 	"context"
-	"log"
+	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/macadmins/nanohubctl/internal/cli"
+	"github.com/macadmins/nanohubctl/internal/utils"
 )
 
 func main() {
-	ctx := context.Background()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	go func() {
+		// Restore default handling once interrupted, so a second Ctrl-C exits immediately
+		<-ctx.Done()
+		stop()
+	}()
 	err := cli.ExecuteWithContext(ctx)
+	stop()
 	if err != nil {
-		log.Fatal(err)
+		os.Exit(utils.ExitCode(err))
 	}
 }
@@ -0,0 +1,203 @@
+// Package nanohub is a client for the NanoHUB DDM and NanoCMD APIs.
+//
+// It is the same client nanohubctl uses, so Go programs can drive a NanoHUB
+// instance without shelling out to the CLI.
+package nanohub
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+)
+
+const (
+	// DDMPath is the path of the KMFDDM API relative to the NanoHUB base URL.
+	DDMPath = "api/v1/ddm"
+	// NanoCMDPath is the path of the NanoCMD API relative to the NanoHUB base URL.
+	NanoCMDPath = "api/v1/nanocmd"
+
+	// DefaultAPIUser is the HTTP Basic username NanoHUB expects.
+	DefaultAPIUser = "nanohub"
+)
+
+// Config holds everything needed to talk to a NanoHUB instance.
+type Config struct {
+	// URL is the base URL of the instance, e.g. https://nanohub.example.com/
+	URL string
+	// APIUser is the HTTP Basic username. DefaultAPIUser is used when empty.
+	APIUser string
+	// APIKey is the HTTP Basic password.
+	APIKey string
+	// HTTPClient is used for every request. http.DefaultClient is used when nil.
+	HTTPClient *http.Client
+	// Retry controls retrying of transient failures. DefaultRetryPolicy is
+	// used when nil.
+	Retry *RetryPolicy
+	// OnRetry, if set, is called before each retry.
+	OnRetry func(RetryEvent)
+}
+
+// Client talks to a single NanoHUB instance. It is safe for concurrent use.
+type Client struct {
+	baseURL    *url.URL
+	apiUser    string
+	apiKey     string
+	httpClient *http.Client
+	retry      RetryPolicy
+	onRetry    func(RetryEvent)
+}
+
+// NewClient returns a Client for the instance described by cfg.
+func NewClient(cfg Config) (*Client, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("nanohub: URL must be provided")
+	}
+	if cfg.APIKey == "" {
+		return nil, errors.New("nanohub: API key must be provided")
+	}
+	baseURL, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, fmt.Errorf("nanohub: invalid URL: %w", err)
+	}
+	c := &Client{
+		baseURL:    baseURL,
+		apiUser:    cfg.APIUser,
+		apiKey:     cfg.APIKey,
+		httpClient: cfg.HTTPClient,
+		retry:      DefaultRetryPolicy,
+		onRetry:    cfg.OnRetry,
+	}
+	if c.apiUser == "" {
+		c.apiUser = DefaultAPIUser
+	}
+	if c.httpClient == nil {
+		c.httpClient = http.DefaultClient
+	}
+	if cfg.Retry != nil {
+		c.retry = *cfg.Retry
+	}
+	return c, nil
+}
+
+// endpoint joins elem onto the API base path below the instance URL.
+func (c *Client) endpoint(base string, elem ...string) *url.URL {
+	u := *c.baseURL
+	u.Path = path.Join(append([]string{u.Path, base}, elem...)...)
+	return &u
+}
+
+// do sends an authenticated request, retrying transient failures of
+// idempotent requests. The caller must close the response body.
+func (c *Client) do(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	maxRetries := 0
+	if idempotent(method) {
+		maxRetries = c.retry.MaxRetries
+	}
+	for attempt := 0; ; attempt++ {
+		resp, err := c.doOnce(ctx, method, u, header, body)
+		if attempt >= maxRetries || !retryable(ctx, resp, err) {
+			return resp, err
+		}
+
+		wait := c.retry.backoff(attempt+1, resp)
+		var reason string
+		if err != nil {
+			reason = err.Error()
+		} else {
+			reason = resp.Status
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if c.onRetry != nil {
+			c.onRetry(RetryEvent{
+				Method:  method,
+				URL:     u.String(),
+				Attempt: attempt + 1,
+				Wait:    wait,
+				Reason:  reason,
+			})
+		}
+		if err := sleep(ctx, wait); err != nil {
+			return nil, err
+		}
+	}
+}
+
+func (c *Client) doOnce(ctx context.Context, method string, u *url.URL, header http.Header, body []byte) (*http.Response, error) {
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.SetBasicAuth(c.apiUser, c.apiKey)
+	return c.httpClient.Do(req)
+}
+
+// getJSON GETs u and decodes a 200 response into v.
+func (c *Client) getJSON(ctx context.Context, u *url.URL, header http.Header, v any) error {
+	resp, err := c.do(ctx, http.MethodGet, u, header, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return newAPIError(resp)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return err
+	}
+	// Read to the end so the connection can be reused
+	_, err = io.Copy(io.Discard, resp.Body)
+	return err
+}
+
+// Change is the server's answer to a mutating request.
+type Change struct {
+	// StatusCode is the HTTP status the server responded with.
+	StatusCode int
+}
+
+// Changed reports whether the server changed anything: KMFDDM answers 204
+// when it did and 304 when it did not.
+func (c Change) Changed() bool {
+	return c.StatusCode != http.StatusNotModified
+}
+
+// send performs a mutating request.
+func (c *Client) send(ctx context.Context, method string, u *url.URL, body []byte) (Change, error) {
+	resp, err := c.do(ctx, method, u, nil, body)
+	if err != nil {
+		return Change{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified || (resp.StatusCode >= 200 && resp.StatusCode < 300) {
+		return Change{StatusCode: resp.StatusCode}, nil
+	}
+	return Change{}, newAPIError(resp)
+}
+
+// Ping checks that a GET of the endpoint at elem below apiPath (DDMPath or
+// NanoCMDPath) succeeds with the client's credentials
+func (c *Client) Ping(ctx context.Context, apiPath string, elem ...string) error {
+	resp, err := c.do(ctx, http.MethodGet, c.endpoint(apiPath, elem...), nil, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return newAPIError(resp)
+	}
+	return nil
+}
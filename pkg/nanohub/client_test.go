@@ -0,0 +1,39 @@
+package nanohub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPing(t *testing.T) {
+	for status, wantErr := range map[int]bool{
+		http.StatusOK:                  false,
+		http.StatusNoContent:           false,
+		http.StatusNotFound:            true,
+		http.StatusUnauthorized:        true,
+		http.StatusInternalServerError: true,
+	} {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/api/v1/nanocmd/profiles" {
+				t.Errorf("Ping() sent GET %s", r.URL.Path)
+			}
+			w.WriteHeader(status)
+		}))
+		c, err := NewClient(Config{URL: srv.URL, APIKey: "key", Retry: &RetryPolicy{}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.Ping(context.Background(), NanoCMDPath, "profiles")
+		var apiErr *APIError
+		if wantErr && (!errors.As(err, &apiErr) || apiErr.StatusCode != status) {
+			t.Errorf("Ping() for a %d = %v, want an APIError", status, err)
+		}
+		if !wantErr && err != nil {
+			t.Errorf("Ping() for a %d = %v, want nil", status, err)
+		}
+		srv.Close()
+	}
+}
@@ -0,0 +1,156 @@
+package nanohub
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+)
+
+// Declaration is a DDM declaration as stored by KMFDDM.
+type Declaration map[string]any
+
+// Identifier returns the declaration's Identifier.
+func (d Declaration) Identifier() string {
+	s, _ := d["Identifier"].(string)
+	return s
+}
+
+// Type returns the declaration's Type.
+func (d Declaration) Type() string {
+	s, _ := d["Type"].(string)
+	return s
+}
+
+// ServerToken returns the ServerToken KMFDDM assigned to the declaration.
+func (d Declaration) ServerToken() string {
+	s, _ := d["ServerToken"].(string)
+	return s
+}
+
+// ListDeclarations returns the identifiers of every declaration on the server.
+func (c *Client) ListDeclarations(ctx context.Context) ([]string, error) {
+	var ids []string
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "declarations"), nil, &ids)
+	return ids, err
+}
+
+// GetDeclaration fetches a single declaration.
+func (c *Client) GetDeclaration(ctx context.Context, identifier string) (Declaration, error) {
+	var decl Declaration
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "declarations", identifier), nil, &decl)
+	return decl, err
+}
+
+// PutDeclaration uploads a JSON encoded declaration, creating or replacing it.
+func (c *Client) PutDeclaration(ctx context.Context, declJSON []byte) (Change, error) {
+	return c.send(ctx, http.MethodPut, c.endpoint(DDMPath, "declarations"), declJSON)
+}
+
+// DeleteDeclaration removes a declaration from the server.
+func (c *Client) DeleteDeclaration(ctx context.Context, identifier string) (Change, error) {
+	return c.send(ctx, http.MethodDelete, c.endpoint(DDMPath, "declarations", identifier), nil)
+}
+
+// DeclarationSets returns the sets a declaration belongs to.
+func (c *Client) DeclarationSets(ctx context.Context, identifier string) ([]string, error) {
+	var sets []string
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "declaration-sets", identifier), nil, &sets)
+	return sets, err
+}
+
+// ListSets returns the names of every set on the server.
+func (c *Client) ListSets(ctx context.Context) ([]string, error) {
+	var sets []string
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "sets"), nil, &sets)
+	return sets, err
+}
+
+// SetDeclarations returns the identifiers of the declarations in a set.
+func (c *Client) SetDeclarations(ctx context.Context, set string) ([]string, error) {
+	var ids []string
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "set-declarations", set), nil, &ids)
+	return ids, err
+}
+
+// AddSetDeclaration adds a declaration to a set.
+func (c *Client) AddSetDeclaration(ctx context.Context, set, identifier string) (Change, error) {
+	return c.send(ctx, http.MethodPut, c.setDeclarationURL(set, identifier), nil)
+}
+
+// RemoveSetDeclaration removes a declaration from a set.
+func (c *Client) RemoveSetDeclaration(ctx context.Context, set, identifier string) (Change, error) {
+	return c.send(ctx, http.MethodDelete, c.setDeclarationURL(set, identifier), nil)
+}
+
+func (c *Client) setDeclarationURL(set, identifier string) *url.URL {
+	u := c.endpoint(DDMPath, "set-declarations", set)
+	q := u.Query()
+	q.Set("declaration", identifier)
+	u.RawQuery = q.Encode()
+	return u
+}
+
+// EnrollmentSets returns the sets assigned to an enrollment.
+func (c *Client) EnrollmentSets(ctx context.Context, enrollmentID string) ([]string, error) {
+	var sets []string
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "enrollment-sets", enrollmentID), nil, &sets)
+	return sets, err
+}
+
+// AddEnrollmentSet assigns a set to an enrollment.
+func (c *Client) AddEnrollmentSet(ctx context.Context, enrollmentID, set string) (Change, error) {
+	return c.send(ctx, http.MethodPut, c.enrollmentSetURL(enrollmentID, set), nil)
+}
+
+// RemoveEnrollmentSet removes a set from an enrollment.
+func (c *Client) RemoveEnrollmentSet(ctx context.Context, enrollmentID, set string) (Change, error) {
+	return c.send(ctx, http.MethodDelete, c.enrollmentSetURL(enrollmentID, set), nil)
+}
+
+func (c *Client) enrollmentSetURL(enrollmentID, set string) *url.URL {
+	u := c.endpoint(DDMPath, "enrollment-sets", enrollmentID)
+	q := u.Query()
+	q.Set("set", set)
+	u.RawQuery = q.Encode()
+	return u
+}
+
+// DeclarationStatus returns the declaration status reported by an enrollment.
+func (c *Client) DeclarationStatus(ctx context.Context, enrollmentID string) (map[string]any, error) {
+	var status map[string]any
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "declaration-status", enrollmentID), nil, &status)
+	return status, err
+}
+
+// StatusValues returns the status item values reported by an enrollment.
+func (c *Client) StatusValues(ctx context.Context, enrollmentID string) (map[string]any, error) {
+	var values map[string]any
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "status-values", enrollmentID), nil, &values)
+	return values, err
+}
+
+// StatusErrors returns the status errors reported by an enrollment.
+func (c *Client) StatusErrors(ctx context.Context, enrollmentID string) (map[string]any, error) {
+	var errs map[string]any
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "status-errors", enrollmentID), nil, &errs)
+	return errs, err
+}
+
+// Tokens returns the DDM sync tokens KMFDDM would send an enrollment.
+func (c *Client) Tokens(ctx context.Context, enrollmentID string) (map[string]any, error) {
+	var tokens map[string]any
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "tokens"), enrollmentHeader(enrollmentID), &tokens)
+	return tokens, err
+}
+
+// DeclarationItems returns the declaration items manifest KMFDDM would send
+// an enrollment.
+func (c *Client) DeclarationItems(ctx context.Context, enrollmentID string) (map[string]any, error) {
+	var items map[string]any
+	err := c.getJSON(ctx, c.endpoint(DDMPath, "declaration-items"), enrollmentHeader(enrollmentID), &items)
+	return items, err
+}
+
+func enrollmentHeader(enrollmentID string) http.Header {
+	return http.Header{"X-Enrollment-Id": []string{enrollmentID}}
+}
@@ -0,0 +1,51 @@
+package nanohub
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// maxErrorBody caps how much of an error response is kept in an APIError.
+const maxErrorBody = 64 << 10
+
+// APIError is returned when NanoHUB answers a request with an unexpected
+// status code.
+type APIError struct {
+	StatusCode int
+	Method     string
+	// Endpoint is the path of the request URL, e.g. /api/v1/ddm/declarations
+	Endpoint string
+	// Body is the response body the server sent with the error.
+	Body string
+}
+
+func (e *APIError) Error() string {
+	msg := fmt.Sprintf("%s %s: %d %s", e.Method, e.Endpoint, e.StatusCode, http.StatusText(e.StatusCode))
+	if e.Body != "" {
+		msg += ": " + e.Body
+	}
+	return msg
+}
+
+// StatusCode returns the HTTP status of the APIError in err's chain, or 0 if
+// there is none.
+func StatusCode(err error) int {
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return apiErr.StatusCode
+	}
+	return 0
+}
+
+func newAPIError(resp *http.Response) *APIError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	return &APIError{
+		StatusCode: resp.StatusCode,
+		Method:     resp.Request.Method,
+		Endpoint:   resp.Request.URL.Path,
+		Body:       string(bytes.TrimSpace(body)),
+	}
+}
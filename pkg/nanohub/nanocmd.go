@@ -0,0 +1,16 @@
+package nanohub
+
+import (
+	"context"
+	"net/http"
+)
+
+// StartWorkflow starts a NanoCMD workflow for an enrollment, e.g.
+// POST /api/v1/nanocmd/workflow/io.micromdm.wf.devinfolog.v1/start?id=9876-5432-1012
+func (c *Client) StartWorkflow(ctx context.Context, workflowName, enrollmentID string) (Change, error) {
+	u := c.endpoint(NanoCMDPath, "workflow", workflowName, "start")
+	q := u.Query()
+	q.Set("id", enrollmentID)
+	u.RawQuery = q.Encode()
+	return c.send(ctx, http.MethodPost, u, nil)
+}
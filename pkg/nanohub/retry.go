@@ -0,0 +1,113 @@
+package nanohub
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how idempotent requests (GET, PUT and DELETE) are
+// retried after transient failures. POST requests are never retried.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt. Zero
+	// disables retrying.
+	MaxRetries int
+	// MinWait is the backoff before the first retry. It doubles with every
+	// further retry.
+	MinWait time.Duration
+	// MaxWait caps the wait between retries, including the one a
+	// Retry-After header sent with a 429 or 503 response asks for.
+	MaxWait time.Duration
+}
+
+// DefaultRetryPolicy is used by NewClient when Config.Retry is nil.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinWait:    500 * time.Millisecond,
+	MaxWait:    30 * time.Second,
+}
+
+// RetryEvent describes a retry that is about to happen.
+type RetryEvent struct {
+	Method  string
+	URL     string
+	Attempt int
+	Wait    time.Duration
+	// Reason is the response status or transport error that triggered the retry.
+	Reason string
+}
+
+func idempotent(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryable reports whether the outcome of an attempt is worth retrying.
+func retryable(ctx context.Context, resp *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// backoff returns the wait before retry number attempt (starting at 1).
+func (p RetryPolicy) backoff(attempt int, resp *http.Response) time.Duration {
+	if resp != nil && (resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode == http.StatusServiceUnavailable) {
+		if d, ok := retryAfter(resp.Header.Get("Retry-After")); ok {
+			// A server asking for hours must not stall the CLI for as long
+			if p.MaxWait > 0 && d > p.MaxWait {
+				d = p.MaxWait
+			}
+			return d
+		}
+	}
+	d := p.MinWait << (attempt - 1)
+	if d <= 0 || (p.MaxWait > 0 && d > p.MaxWait) {
+		d = p.MaxWait
+	}
+	// Equal jitter: keep half the backoff, randomise the rest.
+	half := d / 2
+	if half <= 0 {
+		return d
+	}
+	return half + rand.N(half)
+}
+
+// retryAfter parses a Retry-After header in either of its two forms.
+func retryAfter(v string) (time.Duration, bool) {
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+// sleep waits for d or until ctx is done.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
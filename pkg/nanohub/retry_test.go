@@ -0,0 +1,215 @@
+package nanohub
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestIdempotent(t *testing.T) {
+	for method, want := range map[string]bool{
+		http.MethodGet:    true,
+		http.MethodHead:   true,
+		http.MethodPut:    true,
+		http.MethodDelete: true,
+		http.MethodPost:   false,
+		http.MethodPatch:  false,
+	} {
+		if got := idempotent(method); got != want {
+			t.Errorf("idempotent(%s) = %v, want %v", method, got, want)
+		}
+	}
+}
+
+func TestRetryable(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name   string
+		ctx    context.Context
+		status int
+		err    error
+		want   bool
+	}{
+		{name: "429", status: http.StatusTooManyRequests, want: true},
+		{name: "502", status: http.StatusBadGateway, want: true},
+		{name: "503", status: http.StatusServiceUnavailable, want: true},
+		{name: "504", status: http.StatusGatewayTimeout, want: true},
+		{name: "200", status: http.StatusOK},
+		{name: "404", status: http.StatusNotFound},
+		{name: "500", status: http.StatusInternalServerError},
+		{name: "transport error", err: errors.New("connection reset"), want: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "deadline", err: context.DeadlineExceeded},
+		{name: "context done", ctx: canceled, err: errors.New("connection reset")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := tt.ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+			var resp *http.Response
+			if tt.err == nil {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := retryable(ctx, resp, tt.err); got != tt.want {
+				t.Errorf("retryable() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := RetryPolicy{MinWait: 100 * time.Millisecond, MaxWait: time.Second}
+	for attempt, want := range map[int]time.Duration{
+		1: 100 * time.Millisecond,
+		2: 200 * time.Millisecond,
+		4: 800 * time.Millisecond,
+		5: time.Second,
+		// The shift overflows
+		70: time.Second,
+	} {
+		for range 100 {
+			// Equal jitter keeps the wait between half the backoff and the backoff
+			if d := p.backoff(attempt, nil); d < want/2 || d >= want {
+				t.Fatalf("backoff(%d) = %s, want in [%s, %s)", attempt, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestBackoffRetryAfter(t *testing.T) {
+	p := RetryPolicy{MinWait: 100 * time.Millisecond, MaxWait: time.Minute}
+	resp := func(status int, retryAfter string) *http.Response {
+		return &http.Response{StatusCode: status, Header: http.Header{"Retry-After": {retryAfter}}}
+	}
+	if d := p.backoff(1, resp(http.StatusTooManyRequests, "7")); d != 7*time.Second {
+		t.Errorf("backoff() with Retry-After: 7 = %s, want 7s", d)
+	}
+	date := time.Now().Add(20 * time.Second).UTC().Format(http.TimeFormat)
+	if d := p.backoff(1, resp(http.StatusServiceUnavailable, date)); d <= 18*time.Second || d > 20*time.Second {
+		t.Errorf("backoff() with Retry-After: %s = %s, want about 20s", date, d)
+	}
+	// Waits longer than MaxWait are cut short
+	if d := p.backoff(1, resp(http.StatusTooManyRequests, "86400")); d != time.Minute {
+		t.Errorf("backoff() with Retry-After: 86400 = %s, want 1m", d)
+	}
+	date = time.Now().Add(48 * time.Hour).UTC().Format(http.TimeFormat)
+	if d := p.backoff(1, resp(http.StatusServiceUnavailable, date)); d != time.Minute {
+		t.Errorf("backoff() with Retry-After: %s = %s, want 1m", date, d)
+	}
+	// Only 429 and 503 carry a Retry-After worth honouring
+	if d := p.backoff(1, resp(http.StatusBadGateway, "7")); d >= 100*time.Millisecond {
+		t.Errorf("backoff() for a 502 = %s, want the policy's backoff", d)
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	tests := []struct {
+		value  string
+		want   time.Duration
+		wantOK bool
+	}{
+		{value: ""},
+		{value: "soon"},
+		{value: "-1"},
+		{value: "0", wantOK: true},
+		{value: "120", want: 2 * time.Minute, wantOK: true},
+		{value: "Wed, 21 Oct 2015 07:28:00 GMT", wantOK: true},
+	}
+	for _, tt := range tests {
+		got, ok := retryAfter(tt.value)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("retryAfter(%q) = %s, %v, want %s, %v", tt.value, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+// flakyServer answers 503 to the first failures requests, then 204
+func flakyServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var requests atomic.Int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return srv, &requests
+}
+
+func testClient(t *testing.T, url string, onRetry func(RetryEvent)) *Client {
+	c, err := NewClient(Config{
+		URL:     url,
+		APIKey:  "key",
+		Retry:   &RetryPolicy{MaxRetries: 3, MinWait: time.Millisecond, MaxWait: 2 * time.Millisecond},
+		OnRetry: onRetry,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
+
+func TestClientRetries(t *testing.T) {
+	tests := []struct {
+		name     string
+		method   string
+		failures int32
+		// want are the status returned and the number of requests sent
+		wantStatus   int
+		wantRequests int32
+	}{
+		{name: "recovers", method: http.MethodGet, failures: 2, wantStatus: http.StatusNoContent, wantRequests: 3},
+		{name: "gives up", method: http.MethodPut, failures: 10, wantStatus: http.StatusServiceUnavailable, wantRequests: 4},
+		{name: "POST is not retried", method: http.MethodPost, failures: 1, wantStatus: http.StatusServiceUnavailable, wantRequests: 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, requests := flakyServer(t, tt.failures)
+			var events []RetryEvent
+			c := testClient(t, srv.URL, func(e RetryEvent) { events = append(events, e) })
+			resp, err := c.do(context.Background(), tt.method, c.endpoint(DDMPath, "declarations"), nil, nil)
+			if err != nil {
+				t.Fatalf("do() error = %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.wantStatus {
+				t.Errorf("status = %d, want %d", resp.StatusCode, tt.wantStatus)
+			}
+			if got := requests.Load(); got != tt.wantRequests {
+				t.Errorf("sent %d requests, want %d", got, tt.wantRequests)
+			}
+			if len(events) != int(tt.wantRequests)-1 {
+				t.Fatalf("OnRetry called %d times, want %d", len(events), tt.wantRequests-1)
+			}
+			for i, e := range events {
+				if e.Attempt != i+1 || e.Method != tt.method || e.Reason != "503 Service Unavailable" {
+					t.Errorf("OnRetry event %d = %+v", i, e)
+				}
+			}
+		})
+	}
+}
+
+func TestClientRetryCanceled(t *testing.T) {
+	srv, requests := flakyServer(t, 10)
+	c, err := NewClient(Config{URL: srv.URL, APIKey: "key", Retry: &RetryPolicy{MaxRetries: 3, MinWait: time.Hour, MaxWait: time.Hour}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := c.do(ctx, http.MethodGet, c.endpoint(DDMPath, "declarations"), nil, nil); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("do() error = %v, want context.DeadlineExceeded", err)
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("sent %d requests, want 1", got)
+	}
+}